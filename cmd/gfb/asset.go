@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/arbourd/gfb/internal/transient"
+)
+
+// assetTransport downloads release assets of private GitHub repositories,
+// which github.com answers with 404 Not Found, through the authenticated
+// release asset API. Assets of public repositories are downloaded as any
+// other package, not counting against the API rate limit.
+type assetTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+func (t assetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		return resp, err
+	}
+	org, repo, tag, name, ok := releaseAsset(req.URL)
+	if !ok {
+		return resp, nil
+	}
+
+	u, err := t.assetURL(req, org, repo, tag, name)
+	if err != nil {
+		return nil, fmt.Errorf("release asset %s: %w", req.URL, err)
+	}
+	if len(u) == 0 {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	areq, err := http.NewRequestWithContext(req.Context(), req.Method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	areq.Header.Set("Authorization", "token "+t.token)
+	areq.Header.Set("Accept", "application/octet-stream")
+	// The API redirects to a signed URL, which the client follows without
+	// the token.
+	return t.base.RoundTrip(areq)
+}
+
+// assetURL returns the API URL of the asset named name of the release of
+// org/repo tagged tag, or an empty string if there is no such asset.
+func (t assetTransport) assetURL(req *http.Request, org, repo, tag, name string) (string, error) {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/tags/%s", org, repo, url.PathEscape(tag))
+	rreq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	rreq.Header.Set("Authorization", "token "+t.token)
+	rreq.Header.Set("Accept", "application/vnd.github.v3+json")
+	resp, err := t.base.RoundTrip(rreq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GET %s: %s", u, resp.Status)
+		if transient.Status(resp.StatusCode) {
+			return "", transient.Error{Err: err}
+		}
+		return "", err
+	}
+
+	var release struct {
+		Assets []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"assets"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&release)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range release.Assets {
+		if a.Name == name {
+			return a.URL, nil
+		}
+	}
+	return "", nil
+}
+
+// releaseAsset returns the org, repo, release tag and asset name of a
+// download URL of a GitHub release asset.
+func releaseAsset(u *url.URL) (org, repo, tag, name string, ok bool) {
+	if u.Scheme != "https" || !strings.EqualFold(u.Host, "github.com") {
+		return "", "", "", "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 6 || parts[2] != "releases" || parts[3] != "download" {
+		return "", "", "", "", false
+	}
+	return parts[0], parts[1], parts[4], parts[5], true
+}
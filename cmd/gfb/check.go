@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/arbourd/gfb/pkg/bump"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+)
+
+// check prints a report about the rig without modifying any foods.
+func check(ctx context.Context, report string, opts bump.Options) error {
+	feed, cleanup, err := bump.LoadRig(ctx, &opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	switch report {
+	case "staleness":
+		rows := staleness(ctx, feed, opts)
+		return printStaleness(rows)
+	case "platforms":
+		rows := platformGaps(ctx, feed, opts)
+		return printPlatformGaps(rows)
+	case "homebrew":
+		rows := homebrewDrift(ctx, feed, opts)
+		return printHomebrewDrift(rows)
+	default:
+		return fmt.Errorf("unknown report: %s", report)
+	}
+}
+
+// Staleness describes how far a food lags behind its upstream.
+type Staleness struct {
+	Food    string `json:"food"`
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	// Releases is the number of upstream releases newer than the current version.
+	Releases int `json:"releases_behind"`
+	// Days is the number of days since the oldest of those releases was published.
+	Days int `json:"days_behind"`
+}
+
+// staleness returns the staleness of every GitHub-resolvable food, sorted
+// with the most out of date first.
+func staleness(ctx context.Context, feed []gofish.Food, opts bump.Options) []Staleness {
+	var rows []Staleness
+	for _, f := range feed {
+		if opts.Skip[f.Name] {
+			continue
+		}
+		org, repo := source.GithubRepo(f, opts.Release)
+		if len(org) == 0 {
+			continue
+		}
+
+		s, err := foodStaleness(ctx, f, org, repo, opts)
+		if err != nil {
+			slog.Warn("checking staleness failed", "food", f.Name, "version", f.Version, "phase", "check", "error", err)
+			continue
+		}
+		rows = append(rows, s)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Releases != rows[j].Releases {
+			return rows[i].Releases > rows[j].Releases
+		}
+		if rows[i].Days != rows[j].Days {
+			return rows[i].Days > rows[j].Days
+		}
+		return rows[i].Food < rows[j].Food
+	})
+	return rows
+}
+
+func foodStaleness(ctx context.Context, f gofish.Food, org, repo string, opts bump.Options) (Staleness, error) {
+	s := Staleness{Food: f.Name, Current: f.Version, Latest: f.Version}
+
+	version, err := semver.NewVersion(f.Version)
+	if err != nil {
+		return s, fmt.Errorf("semver: %w", err)
+	}
+
+	var constraint *semver.Constraints
+	if cs := source.Constraint(f, opts.Constraints); len(cs) > 0 {
+		constraint, err = semver.NewConstraint(cs)
+		if err != nil {
+			return s, fmt.Errorf("parsing constraint %s: %w", cs, err)
+		}
+	}
+
+	releases, err := source.ListReleases(ctx, opts.GithubClient, org, repo, source.FoodChannel(f, opts.Channels))
+	if err != nil {
+		return s, fmt.Errorf("github releases: %w", err)
+	}
+
+	latest := version
+	var oldest time.Time
+	for _, release := range releases {
+		v, err := semver.NewVersion(release.GetTagName())
+		if err != nil || !v.GreaterThan(version) || (constraint != nil && !constraint.Check(v)) {
+			continue
+		}
+
+		s.Releases++
+		if v.GreaterThan(latest) {
+			latest = v
+		}
+		if published := release.GetPublishedAt().Time; oldest.IsZero() || published.Before(oldest) {
+			oldest = published
+		}
+	}
+
+	s.Latest = latest.String()
+	if !oldest.IsZero() {
+		s.Days = int(time.Since(oldest).Hours() / 24)
+	}
+	return s, nil
+}
+
+func printStaleness(rows []Staleness) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FOOD\tCURRENT\tLATEST\tRELEASES BEHIND\tDAYS BEHIND")
+	for _, r := range rows {
+		fmt.Fprintln(w, r.Food+"\t"+r.Current+"\t"+r.Latest+"\t"+strconv.Itoa(r.Releases)+"\t"+strconv.Itoa(r.Days))
+	}
+	return w.Flush()
+}
+
+// PlatformGap is a platform a food has no package for, although the upstream's
+// latest release publishes an asset for it.
+type PlatformGap struct {
+	Food     string
+	Platform string
+	Asset    string
+}
+
+// coveragePlatforms are the platforms checked for gaps, with the names
+// upstreams commonly use for them in asset file names.
+var coveragePlatforms = []struct {
+	OS, Arch string
+	OSNames  []string
+	// ArchNames match assets built for the platform; universal darwin
+	// binaries run natively on arm64.
+	ArchNames []string
+}{
+	{OS: "darwin", Arch: "arm64", OSNames: []string{"darwin", "macos", "mac", "osx", "apple"}, ArchNames: []string{"arm64", "aarch64", "universal"}},
+	{OS: "linux", Arch: "arm64", OSNames: []string{"linux"}, ArchNames: []string{"arm64", "aarch64"}},
+}
+
+func platformGaps(ctx context.Context, feed []gofish.Food, opts bump.Options) []PlatformGap {
+	var gaps []PlatformGap
+	for _, f := range feed {
+		if opts.Skip[f.Name] {
+			continue
+		}
+
+		var missing []int
+		for i, p := range coveragePlatforms {
+			if f.GetPackage(p.OS, p.Arch) == nil {
+				missing = append(missing, i)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		org, repo := source.GithubRepo(f, opts.Release)
+		if len(org) == 0 {
+			continue
+		}
+		release, err := source.LatestRelease(ctx, opts.GithubClient, org, repo, source.Constraint(f, opts.Constraints), source.FoodChannel(f, opts.Channels))
+		if err != nil {
+			slog.Warn("github release", "food", f.Name, "version", f.Version, "phase", "check", "error", err)
+			continue
+		}
+
+		for _, i := range missing {
+			p := coveragePlatforms[i]
+			for _, asset := range release.Assets {
+				name := strings.ToLower(asset.GetName())
+				if containsAny(name, p.OSNames) && containsAny(name, p.ArchNames) {
+					gaps = append(gaps, PlatformGap{Food: f.Name, Platform: p.OS + "/" + p.Arch, Asset: asset.GetBrowserDownloadURL()})
+					break
+				}
+			}
+		}
+	}
+	return gaps
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func printPlatformGaps(rows []PlatformGap) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FOOD\tPLATFORM\tASSET")
+	for _, r := range rows {
+		fmt.Fprintln(w, r.Food+"\t"+r.Platform+"\t"+r.Asset)
+	}
+	return w.Flush()
+}
+
+const homebrewFormulaURL = "https://formulae.brew.sh/api/formula/%s.json"
+
+// HomebrewDrift is a food that Homebrew packages a much newer version of,
+// which suggests the food is mapped to the wrong upstream.
+type HomebrewDrift struct {
+	Food     string
+	Current  string
+	Homebrew string
+}
+
+func homebrewDrift(ctx context.Context, feed []gofish.Food, opts bump.Options) []HomebrewDrift {
+	var rows []HomebrewDrift
+	for _, f := range feed {
+		if _, pin := source.SplitPin(f.Name); opts.Skip[f.Name] || len(pin) > 0 {
+			continue
+		}
+
+		version, err := semver.NewVersion(f.Version)
+		if err != nil {
+			continue
+		}
+
+		stable, err := homebrewVersion(ctx, f.Name, opts.HTTPClient)
+		if err != nil {
+			slog.Warn("homebrew", "food", f.Name, "version", f.Version, "phase", "check", "error", err)
+			continue
+		}
+		if len(stable) == 0 {
+			continue
+		}
+		brewVersion, err := semver.NewVersion(stable)
+		if err != nil {
+			continue
+		}
+
+		if versionsAhead(brewVersion, version) {
+			rows = append(rows, HomebrewDrift{Food: f.Name, Current: f.Version, Homebrew: brewVersion.String()})
+		}
+	}
+	return rows
+}
+
+// versionsAhead reports whether v is multiple releases ahead of current: a
+// newer major version, or at least two minor versions within the same major.
+func versionsAhead(v, current *semver.Version) bool {
+	if v.Major() != current.Major() {
+		return v.Major() > current.Major()
+	}
+	return v.Minor()-current.Minor() >= 2
+}
+
+// homebrewVersion returns the stable version of the Homebrew formula with
+// the given name, or an empty string if there is no such formula.
+func homebrewVersion(ctx context.Context, name string, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(homebrewFormulaURL, name), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+
+	var formula struct {
+		Versions struct {
+			Stable string `json:"stable"`
+		} `json:"versions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&formula); err != nil {
+		return "", err
+	}
+	return formula.Versions.Stable, nil
+}
+
+func printHomebrewDrift(rows []HomebrewDrift) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FOOD\tCURRENT\tHOMEBREW")
+	for _, r := range rows {
+		fmt.Fprintln(w, r.Food+"\t"+r.Current+"\t"+r.Homebrew)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/arbourd/gfb/pkg/bump"
+	"github.com/arbourd/gfb/pkg/checksum"
+	"github.com/arbourd/gfb/pkg/source"
+)
+
+// Config is the part of the options that can be loaded from a JSON file, and
+// reloaded while serving.
+type Config struct {
+	// Skip lists foods that are never bumped.
+	Skip []string `json:"skip"`
+	// Release maps foods to the org/repo GitHub repository they are released
+	// from, for foods whose homepage is not that repository.
+	Release releaseConfig `json:"release"`
+	// Constraints maps foods to a semver constraint that releases must
+	// satisfy to be bumped to.
+	Constraints map[string]string `json:"constraints"`
+	// Channels maps foods to the release channel they track: stable, rc or
+	// nightly. Release candidates and nightly builds are told apart by the
+	// prerelease flag of releases and their tags, such as v1.2.0-rc.1 or
+	// v1.2.0-nightly.20240101. Prereleases only satisfy constraints naming a
+	// prerelease.
+	Channels map[string]string `json:"channels"`
+	// Checksums maps foods to the provider their checksums are taken from
+	// before downloading their packages: checksums-file, github-digest or
+	// object-store.
+	Checksums map[string]string `json:"checksums"`
+	// Mirrors rewrite package URLs when downloading them to compute their
+	// checksums, in order.
+	Mirrors []checksum.Mirror `json:"mirrors"`
+	// DownloadHeaders maps hosts to headers sent with HTTPS requests to them,
+	// such as credentials of gated package hosts. ${VAR} in values is
+	// replaced by the environment variable, so secrets need not be kept in
+	// the config. They are read at startup, not reloaded.
+	DownloadHeaders map[string]map[string]string `json:"download_headers"`
+}
+
+// releaseConfig maps foods to the org/repo they are released from. Unlike a
+// plain map, it rejects a food listed twice with different repositories
+// rather than keeping the last.
+type releaseConfig map[string]string
+
+func (rc *releaseConfig) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if t != json.Delim('{') {
+		return fmt.Errorf("release: expected an object")
+	}
+
+	m := releaseConfig{}
+	var duplicate []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		food := t.(string)
+		var repo string
+		if err := dec.Decode(&repo); err != nil {
+			return fmt.Errorf("release of %s: %w", food, err)
+		}
+		if prev, ok := m[food]; ok && prev != repo {
+			duplicate = append(duplicate, fmt.Sprintf("%s (%s and %s)", food, prev, repo))
+		}
+		m[food] = repo
+	}
+	if len(duplicate) > 0 {
+		return fmt.Errorf("release: foods released from more than one repository: %s", strings.Join(duplicate, ", "))
+	}
+	*rc = m
+	return nil
+}
+
+// loadConfig reads the config file at path.
+func loadConfig(path string) (Config, error) {
+	var c Config
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("config: %w", err)
+	}
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return c, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// apply adds the skip list, release overrides, constraints, channels,
+// checksum providers and mirrors of c to opts.
+func (c Config) apply(opts *bump.Options) error {
+	skip, err := skipToMap(strings.Join(c.Skip, ","))
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	release := map[string]source.GithubRelease{}
+	for food, r := range c.Release {
+		m, err := releaseToMap(food + ":" + r)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		release[food] = m[food]
+	}
+	if err := checkSkipRelease(skip, release); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	for food, cs := range c.Constraints {
+		if _, err := semver.NewConstraint(cs); err != nil {
+			return fmt.Errorf("config: constraint of %s: %w", food, err)
+		}
+	}
+	for food, ch := range c.Channels {
+		if !source.ValidChannel(ch) {
+			return fmt.Errorf("config: channel of %s: unknown channel %s", food, ch)
+		}
+	}
+	for food, p := range c.Checksums {
+		switch p {
+		case checksum.ProviderDownload, checksum.ProviderChecksumsFile, checksum.ProviderGithubDigest, checksum.ProviderObjectStore:
+		default:
+			return fmt.Errorf("config: checksum provider of %s: unknown provider %s", food, p)
+		}
+	}
+	for _, m := range c.Mirrors {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+
+	opts.Skip = mergeMaps(opts.Skip, skip)
+	opts.Release = mergeMaps(opts.Release, release)
+	opts.Constraints = mergeMaps(opts.Constraints, c.Constraints)
+	opts.Channels = mergeMaps(opts.Channels, c.Channels)
+	opts.Checksums = mergeMaps(opts.Checksums, c.Checksums)
+	opts.Mirrors = append(c.Mirrors, opts.Mirrors...)
+	return nil
+}
+
+// downloadHeaders returns the download headers of c, keyed by lowercase
+// host, with environment variables in their values expanded.
+func (c Config) downloadHeaders() map[string]map[string]string {
+	m := map[string]map[string]string{}
+	for host, headers := range c.DownloadHeaders {
+		expanded := map[string]string{}
+		for k, v := range headers {
+			expanded[k] = os.ExpandEnv(v)
+		}
+		m[strings.ToLower(host)] = expanded
+	}
+	return m
+}
+
+// mergeMaps returns a new map with the entries of a and b. Entries of b
+// replace those of a.
+func mergeMaps[V any](a, b map[string]V) map[string]V {
+	m := make(map[string]V, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
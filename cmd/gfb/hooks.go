@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"github.com/arbourd/gfb/pkg/bump"
+	"github.com/fishworks/gofish"
+)
+
+// commandHooks returns hooks running the shell commands pre and post, if set,
+// before and after each food is bumped. The food and its versions are passed
+// in GFB_FOOD, GFB_OLD_VERSION and GFB_NEW_VERSION. A pre-bump command
+// exiting with a non-zero status vetoes the bump; a failing post-bump command
+// is only logged.
+func commandHooks(pre, post string) bump.Hooks {
+	var h bump.Hooks
+	if len(pre) > 0 {
+		h.BeforeBump = append(h.BeforeBump, func(ctx context.Context, old gofish.Food, new *gofish.Food) error {
+			err := runHook(ctx, pre, old.Name, old.Version, new.Version, "")
+			if err != nil {
+				return fmt.Errorf("pre-bump command: %w", err)
+			}
+			return nil
+		})
+	}
+	if len(post) > 0 {
+		h.AfterBump = append(h.AfterBump, func(ctx context.Context, res bump.Result) {
+			err := runHook(ctx, post, res.Food, res.OldVersion, res.NewVersion, res.ReleaseURL)
+			if err != nil {
+				slog.Warn("post-bump command failed", "food", res.Food, "version", res.OldVersion, "phase", "hook", "error", err)
+			}
+		})
+	}
+	return h
+}
+
+// runHook runs command with sh, with the food and its versions in its
+// environment. Its output goes to stderr, so it does not mix with diffs.
+func runHook(ctx context.Context, command, food, oldVersion, newVersion, releaseURL string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"GFB_FOOD="+food,
+		"GFB_OLD_VERSION="+oldVersion,
+		"GFB_NEW_VERSION="+newVersion,
+		"GFB_RELEASE_URL="+releaseURL,
+	)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
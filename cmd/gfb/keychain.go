@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// keychainService is the service tokens are stored under in the platform
+// secret store.
+const keychainService = "gfb"
+
+const (
+	TokenGithub = "github"
+	TokenRig    = "rig"
+)
+
+// keychainToken returns the token of kind stored in the platform secret
+// store, or an empty string if there is none or the store is unavailable.
+func keychainToken(kind string) string {
+	token, err := keyring.Get(keychainService, kind)
+	if err != nil {
+		if !errors.Is(err, keyring.ErrNotFound) {
+			slog.Debug("reading keychain failed", "token", kind, "error", err)
+		}
+		return ""
+	}
+	slog.Debug("using token", "token", kind, "source", "keychain")
+	return token
+}
+
+// readToken reads a token of kind from stdin. A terminal is prompted for it
+// without echoing it; a token piped in is read up to the end of its line.
+func readToken(kind string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "%s token: ", kind)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(b)), err
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// token stores or deletes a token in the platform secret store. Tokens to
+// store are read from stdin, so they are not kept in the shell history.
+func token(action, kind string) error {
+	if kind != TokenGithub && kind != TokenRig {
+		return fmt.Errorf("unknown token: %s", kind)
+	}
+
+	switch action {
+	case "set":
+		t, err := readToken(kind)
+		if err != nil {
+			return fmt.Errorf("reading token: %w", err)
+		}
+		if len(t) == 0 {
+			return errors.New("empty token")
+		}
+		err = keyring.Set(keychainService, kind, t)
+		if err != nil {
+			return fmt.Errorf("storing token: %w", err)
+		}
+		slog.Info("stored token", "token", kind)
+	case "delete":
+		err := keyring.Delete(keychainService, kind)
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("deleting token: %w", err)
+		}
+		slog.Info("deleted token", "token", kind)
+	default:
+		return fmt.Errorf("unknown token action: %s", action)
+	}
+	return nil
+}
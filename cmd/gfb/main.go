@@ -0,0 +1,505 @@
+// Command gfb bumps the foods of a fish food rig to the latest releases of
+// their upstreams.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/arbourd/gfb/pkg/bump"
+	"github.com/arbourd/gfb/pkg/rig"
+	"github.com/arbourd/gfb/pkg/source"
+)
+
+func main() {
+	ctx := context.Background()
+
+	auth := ""
+	rigURL := "https://github.com/fishworks/fish-food"
+	skip := ""
+	release := `consul:hashicorp/consul,kubectl:kubernetes/kubernetes,nomad:hashicorp/nomad,terraform:hashicorp/terraform,vagrant:hashicorp/vagrant,vault:hashicorp/vault`
+
+	opts := bump.Options{
+		Rig: rigURL,
+
+		AuthorName:  "arbourd",
+		AuthorEmail: "arbourd@users.noreply.github.com",
+
+		GithubAuthToken: auth,
+	}
+
+	cmd := "bump"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	logLevel := fs.String("log-level", "info", "minimum log `level`: debug, info, warn, error")
+	logFormat := fs.String("log-format", "text", "log `format`: text, json")
+	fs.StringVar(&opts.Rig, "rig", opts.Rig, "`url` of the rig to clone, or path of a local checkout of it to change in place")
+	var hopts httpOptions
+	fs.StringVar(&hopts.Proxy, "proxy", "", "send HTTP requests through the proxy at `url` (default from HTTP_PROXY, HTTPS_PROXY and NO_PROXY)")
+	fs.StringVar(&hopts.CABundle, "ca-bundle", "", "trust the PEM certificates at `path` besides the system roots")
+	fs.StringVar(&hopts.ClientCert, "client-cert", "", "present the PEM client certificate at `path` to servers requesting one")
+	fs.StringVar(&hopts.ClientKey, "client-key", "", "PEM private key at `path` of the client certificate")
+	fs.IntVar(&hopts.MaxIdleConns, "max-idle-conns", 100, "keep at most `n` idle HTTP connections for reuse, or 0 for no limit")
+	fs.IntVar(&hopts.MaxIdleConnsPerHost, "max-idle-conns-per-host", 16, "keep at most `n` idle HTTP connections to each host for reuse")
+	fs.DurationVar(&hopts.IdleConnTimeout, "idle-conn-timeout", 90*time.Second, "close idle HTTP connections after `duration`")
+	fs.BoolVar(&hopts.KeepAlive, "keep-alive", true, "reuse HTTP connections between requests")
+	fs.BoolVar(&hopts.HTTP2, "http2", true, "use HTTP/2 with servers supporting it")
+	fs.StringVar(&opts.GithubAuthToken, "github-token", opts.GithubAuthToken, "GitHub `token` (default from the keychain, gh, GITHUB_TOKEN or a git credential helper)")
+	fs.StringVar(&opts.RigToken, "rig-token", os.Getenv("GFB_RIG_TOKEN"), "`token` authenticating to rigs over HTTPS; required to push to rigs not on github.com, which are never sent the GitHub token")
+	fs.StringVar(&opts.RigHost, "rig-host", "", "`forge` hosting the rig, on which pull requests are opened: github, gitlab, gitea (default detected from the rig URL)")
+	fs.StringVar(&opts.SSHKey, "ssh-key", "", "authenticate to rigs cloned over SSH with the private key at `path` instead of the SSH agent")
+	fs.StringVar(&opts.RigBranch, "rig-branch", "", "clone and open pull requests against `branch` of the rig instead of its default branch")
+	fs.BoolVar(&opts.API, "api", false, "read and commit to the rig through the GitHub API instead of cloning it")
+	fs.StringVar(&opts.FoodDir, "food-dir", "", "`dir` of the rig holding its foods, or . for its root (default detected)")
+	fs.StringVar(&opts.Workdir, "workdir", "", "keep the rig clone in `dir` between runs, pulling it instead of cloning")
+	fs.StringVar(&opts.DiffRange, "diff-range", "", "only process foods added or modified in the git `range` of a local rig checkout, such as origin/main...HEAD")
+	fs.StringVar(&skip, "skip", skip, "comma-separated `foods` never to bump")
+	fs.StringVar(&release, "release", release, "comma-separated `food:org/repo` GitHub repositories foods are released from, if not their homepage")
+	config := fs.String("config", "", "load the skip list, release overrides and constraints from the JSON config at `path`")
+
+	var report, planPath, statePath, preBumpCmd, postBumpCmd, notifyWebhooks, emailTo, statsdAddr, statsdTags string
+	var dogStatsD bool
+	var sopts ServeOptions
+	switch cmd {
+	case "bump", "serve", "watch", "plan":
+		fs.BoolVar(&opts.SyncDescription, "sync-description", true, "refresh food descriptions from the upstream GitHub repository")
+		fs.BoolVar(&opts.OpenIssues, "open-issues", false, "open an issue on the rig for each deprecation candidate")
+		fs.IntVar(&opts.FailureIssues, "failure-issues", 0, "open an issue on the rig for each food that could not be bumped in `n` consecutive runs, closing it once the food is bumped (requires -state)")
+		fs.BoolVar(&opts.HoldMajor, "hold-major", false, "do not apply major version bumps")
+		fs.BoolVar(&opts.AllowDowngrade, "allow-downgrade", false, "bump foods to upstream releases older than them, such as when the latest release was deleted")
+		fs.BoolVar(&opts.ReplaceYanked, "replace-yanked", false, "bump foods whose release was deleted upstream to the newest release left")
+		fs.BoolVar(&opts.PinMajor, "pin-major", false, "on a major version bump, preserve the previous major in a pinned food@major file")
+		fs.BoolVar(&opts.CheckURLs, "check-urls", false, "report package URLs of current versions that no longer exist")
+		fs.BoolVar(&opts.SandboxInstall, "sandbox-install", false, "install the package of each updated food for this platform into a temporary prefix and run its test function before writing it")
+		fs.BoolVar(&opts.SmokeTest, "smoke-test", false, "run the executables of each food installed with -sandbox-install with --version, skipping foods that do not print the new version (implies -sandbox-install)")
+		fs.StringVar(&opts.GofishVersion, "gofish-version", "", "skip updated foods setting fields the gofish `version` does not read, such as 0.14.0")
+		fs.BoolVar(&opts.VerifyChecksums, "verify-checksums", false, "recompute the checksums of up-to-date foods, reporting packages changed without a new version")
+		fs.BoolVar(&opts.ObjectChecksums, "object-checksums", false, "use the checksums S3 and GCS publish for packages hosted there instead of downloading them")
+		fs.StringVar(&opts.ReportJSON, "report-json", "", "write a JSON report of the run to `path`, or - for stdout")
+		fs.BoolVar(&opts.Diff, "diff", false, "print a diff of each change")
+		fs.StringVar(&statePath, "state", "", "persist what runs saw of each food in the state store at `path`")
+		fs.BoolVar(&opts.SinceLastRun, "since-last-run", false, "skip foods the state confirms were up to date within the freshness window")
+		fs.DurationVar(&opts.Freshness, "freshness", 24*time.Hour, "how long a food confirmed up to date is not checked again with -since-last-run")
+		fs.StringVar(&opts.OTLPEndpoint, "otlp-endpoint", otlpEndpoint(), "export traces of each run to the OTLP/HTTP traces endpoint at `url`, such as http://localhost:4318/v1/traces")
+		fs.StringVar(&opts.AlertWebhook, "alert-webhook", "", "alert the PagerDuty Events API or Opsgenie Alert API at `url` to checksum drift and mismatches, or \"pagerduty\"")
+		fs.StringVar(&opts.AlertKey, "alert-key", os.Getenv("GFB_ALERT_KEY"), "PagerDuty routing `key` or Opsgenie API key of -alert-webhook")
+		fs.StringVar(&statsdAddr, "statsd", "", "send the timing and outcome of each food to the StatsD server at `host:port`")
+		fs.BoolVar(&dogStatsD, "dogstatsd", false, "tag StatsD metrics with the food and its action in the DogStatsD format")
+		fs.StringVar(&statsdTags, "statsd-tags", "", "comma-separated `tags` added to DogStatsD metrics, such as env:prod")
+		fs.StringVar(&opts.Pushgateway, "pushgateway", "", "push the metrics of each run to the Prometheus Pushgateway at `url`")
+		fs.BoolVar(&opts.CheckRun, "check-run", false, "publish the results of each run as a check run on the head commit of the rig")
+		fs.StringVar(&opts.ReportSARIF, "report-sarif", "", "write validation findings as SARIF to `path`")
+		fs.BoolVar(&opts.FailFast, "fail-fast", false, "stop the run at the first food that fails, skipping the foods after it")
+		fs.IntVar(&opts.MaxErrors, "max-errors", 0, "exit successfully if no more than `n` foods fail")
+		fs.BoolVar(&opts.Retry, "retry", true, "retry foods that failed transiently once at the end of the run")
+		fs.DurationVar(&opts.RetryDelay, "retry-delay", 30*time.Second, "how long to wait before retrying transient failures")
+		fs.DurationVar(&opts.Timeout, "timeout", 0, "stop the run after `duration`, reporting foods not reached as not attempted")
+		fs.DurationVar(&opts.FoodTimeout, "food-timeout", 0, "fail a food that takes longer than `duration` to process")
+		fs.StringVar(&preBumpCmd, "pre-bump-cmd", "", "run `command` with sh before each bump, vetoing it if it fails; the food and versions are in GFB_FOOD, GFB_OLD_VERSION and GFB_NEW_VERSION")
+		fs.StringVar(&postBumpCmd, "post-bump-cmd", "", "run `command` with sh after each bump, with the food and versions in its environment as with -pre-bump-cmd")
+		if cmd == "plan" {
+			fs.StringVar(&planPath, "out", "gfb.plan.json", "write the plan to `path`")
+		} else {
+			fs.BoolVar(&opts.DryRun, "dry-run", false, "print a diff of each change instead of writing it")
+			fs.StringVar(&opts.AuditLog, "audit-log", "", "append each applied change to the JSON Lines audit log at `path`")
+			fs.BoolVar(&opts.OpenPR, "open-pr", false, "commit the changes to a new branch and open a pull request on the rig")
+			fs.DurationVar(&opts.LockTTL, "lock-ttl", time.Hour, "break a lock on the rig held for longer than `duration`")
+			fs.StringVar(&notifyWebhooks, "notify-webhook", os.Getenv("GFB_NOTIFY_WEBHOOK"), "comma-separated Slack or Discord incoming webhook `urls` posted a summary of each run")
+			fs.StringVar(&opts.ReportWebhook, "report-webhook", "", "post the JSON report of each run to `url`")
+			fs.StringVar(&opts.ReportWebhookSecret, "report-webhook-secret", os.Getenv("GFB_REPORT_WEBHOOK_SECRET"), "`secret` signing the reports posted to -report-webhook with HMAC-SHA256")
+			fs.StringVar(&opts.SMTPAddr, "smtp-addr", "", "email the report of each run through the SMTP server at `host:port`")
+			fs.StringVar(&opts.SMTPUser, "smtp-user", "", "`user` authenticating to the SMTP server")
+			fs.StringVar(&opts.SMTPPassword, "smtp-password", os.Getenv("GFB_SMTP_PASSWORD"), "`password` authenticating to the SMTP server")
+			fs.StringVar(&opts.SMTPFrom, "smtp-from", "gfb@localhost", "`address` reports are emailed from")
+			fs.StringVar(&emailTo, "email-to", "", "comma-separated `severity:address` recipients emailed the report of runs at least that severe: error, warning or info")
+		}
+		if cmd == "watch" {
+			fs.DurationVar(&sopts.Interval, "interval", 5*time.Minute, "time between polls of the rig")
+		}
+		if cmd == "serve" {
+			fs.DurationVar(&sopts.Interval, "interval", 6*time.Hour, "time between runs")
+			fs.StringVar(&sopts.Addr, "addr", "", "listen for HTTP requests on `address`")
+			fs.StringVar(&sopts.WebhookSecret, "webhook-secret", os.Getenv("GFB_WEBHOOK_SECRET"), "`secret` validating GitHub release webhooks sent to /webhook")
+			fs.StringVar(&sopts.AdminToken, "admin-token", os.Getenv("GFB_ADMIN_TOKEN"), "bearer `token` authorizing requests to the admin endpoints")
+		}
+	case "apply":
+		fs.StringVar(&planPath, "plan", "gfb.plan.json", "apply the plan at `path`")
+		fs.BoolVar(&opts.Diff, "diff", false, "print a diff of each change")
+		fs.StringVar(&opts.AuditLog, "audit-log", "", "append each applied change to the JSON Lines audit log at `path`")
+		fs.BoolVar(&opts.OpenPR, "open-pr", false, "commit the changes to a new branch and open a pull request on the rig")
+		fs.DurationVar(&opts.LockTTL, "lock-ttl", time.Hour, "break a lock on the rig held for longer than `duration`")
+		fs.StringVar(&notifyWebhooks, "notify-webhook", os.Getenv("GFB_NOTIFY_WEBHOOK"), "comma-separated Slack or Discord incoming webhook `urls` posted a summary of each run")
+		fs.StringVar(&opts.ReportWebhook, "report-webhook", "", "post the JSON report of each run to `url`")
+		fs.StringVar(&opts.ReportWebhookSecret, "report-webhook-secret", os.Getenv("GFB_REPORT_WEBHOOK_SECRET"), "`secret` signing the reports posted to -report-webhook with HMAC-SHA256")
+		fs.StringVar(&opts.SMTPAddr, "smtp-addr", "", "email the report of each run through the SMTP server at `host:port`")
+		fs.StringVar(&opts.SMTPUser, "smtp-user", "", "`user` authenticating to the SMTP server")
+		fs.StringVar(&opts.SMTPPassword, "smtp-password", os.Getenv("GFB_SMTP_PASSWORD"), "`password` authenticating to the SMTP server")
+		fs.StringVar(&opts.SMTPFrom, "smtp-from", "gfb@localhost", "`address` reports are emailed from")
+		fs.StringVar(&emailTo, "email-to", "", "comma-separated `severity:address` recipients emailed the report of runs at least that severe: error, warning or info")
+	case "check":
+		fs.StringVar(&report, "report", "staleness", "report to print: staleness, platforms, homebrew")
+	case "stats":
+	case "token":
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: gfb token set|delete [github|rig]\n")
+			fs.PrintDefaults()
+		}
+	default:
+		fatal(fmt.Errorf("unknown command: %s", cmd))
+	}
+	fs.Parse(args)
+
+	err := setupLogging(*logLevel, *logFormat)
+	if err != nil {
+		fatal(err)
+	}
+
+	opts.Skip, err = skipToMap(skip)
+	if err != nil {
+		fatal(err)
+	}
+	opts.Release, err = releaseToMap(release)
+	if err != nil {
+		fatal(err)
+	}
+	// The default release overrides may name foods the user skips.
+	releaseSet := false
+	fs.Visit(func(f *flag.Flag) { releaseSet = releaseSet || f.Name == "release" })
+	if releaseSet {
+		err = checkSkipRelease(opts.Skip, opts.Release)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	if cmd == "token" {
+		action, kind := fs.Arg(0), fs.Arg(1)
+		if len(kind) == 0 {
+			kind = TokenGithub
+		}
+		err := token(action, kind)
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if len(opts.GithubAuthToken) == 0 {
+		opts.GithubAuthToken = keychainToken(TokenGithub)
+	}
+	if len(opts.GithubAuthToken) == 0 {
+		opts.GithubAuthToken = resolveToken(ctx)
+	}
+	if len(opts.RigToken) == 0 {
+		opts.RigToken = keychainToken(TokenRig)
+	}
+
+	if len(opts.GofishVersion) > 0 {
+		if err := bump.CheckGofishVersion(opts.GofishVersion); err != nil {
+			fatal(err)
+		}
+	}
+
+	opts.Hooks = commandHooks(preBumpCmd, postBumpCmd)
+	if len(opts.Pushgateway) > 0 {
+		opts.Metrics = bump.NewMetrics()
+	}
+	if len(statsdAddr) > 0 {
+		opts.StatsD, err = bump.DialStatsD(statsdAddr)
+		if err != nil {
+			fatal(err)
+		}
+		defer opts.StatsD.Close()
+		opts.StatsD.DogStatsD = dogStatsD
+		for _, tag := range strings.Split(statsdTags, ",") {
+			if tag = strings.TrimSpace(tag); len(tag) > 0 {
+				opts.StatsD.Tags = append(opts.StatsD.Tags, tag)
+			}
+		}
+	}
+	if len(opts.OTLPEndpoint) > 0 {
+		opts.OTLPHeaders = otlpHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	}
+	opts.EmailTo, err = emailToMap(emailTo)
+	if err != nil {
+		fatal(err)
+	}
+	for _, hook := range strings.Split(notifyWebhooks, ",") {
+		if hook = strings.TrimSpace(hook); len(hook) > 0 {
+			opts.NotifyWebhooks = append(opts.NotifyWebhooks, hook)
+		}
+	}
+
+	sopts.Config = *config
+	base := opts
+	if len(*config) > 0 {
+		c, err := loadConfig(*config)
+		if err != nil {
+			fatal(err)
+		}
+		err = c.apply(&opts)
+		if err != nil {
+			fatal(err)
+		}
+		hopts.DownloadHeaders = c.downloadHeaders()
+	}
+	hopts.GithubToken = opts.GithubAuthToken
+	opts.HTTPClient, err = setupHTTP(hopts)
+	if err != nil {
+		fatal(err)
+	}
+	base.HTTPClient = opts.HTTPClient
+	if opts.SinceLastRun && len(statePath) == 0 {
+		fatal(errors.New("-since-last-run requires -state"))
+	}
+	if opts.FailureIssues > 0 && len(statePath) == 0 {
+		fatal(errors.New("-failure-issues requires -state"))
+	}
+	if len(opts.AlertWebhook) > 0 && len(opts.AlertKey) == 0 {
+		fatal(errors.New("-alert-webhook requires -alert-key"))
+	}
+	if len(statePath) > 0 {
+		opts.State, err = bump.OpenState(statePath)
+		if err != nil {
+			fatal(err)
+		}
+		defer opts.State.Close()
+		base.State = opts.State
+	}
+
+	switch cmd {
+	case "bump":
+		res, err := bump.New(bump.WithOptions(opts)).Run(ctx)
+		if errors.Is(err, rig.ErrLocked) {
+			slog.Warn(err.Error())
+			os.Exit(bump.ExitTransient)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		os.Exit(res.Status)
+	case "plan":
+		opts.Plan = &bump.Plan{Rig: opts.Rig, Created: time.Now().UTC()}
+		res, err := bump.New(bump.WithOptions(opts)).Run(ctx)
+		if err != nil {
+			fatal(err)
+		}
+		err = opts.Plan.Write(planPath)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("wrote plan", "path", planPath, "foods", len(opts.Plan.Results), "files", len(opts.Plan.Files))
+		os.Exit(res.Status)
+	case "apply":
+		err := bump.Apply(ctx, planPath, opts)
+		if errors.Is(err, rig.ErrLocked) {
+			slog.Warn(err.Error())
+			os.Exit(bump.ExitTransient)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	case "serve":
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		err := serve(ctx, sopts, base, opts)
+		if err != nil {
+			fatal(err)
+		}
+	case "watch":
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		err := watch(ctx, sopts.Interval, opts)
+		if err != nil {
+			fatal(err)
+		}
+	case "check":
+		err := check(ctx, report, opts)
+		if err != nil {
+			fatal(err)
+		}
+	case "stats":
+		err := stats(ctx, opts)
+		if err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// setupLogging configures the default structured logger.
+func setupLogging(level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("log level: %w", err)
+	}
+	handlerOpts := &slog.HandlerOptions{Level: l}
+
+	switch format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, handlerOpts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts)))
+	default:
+		return fmt.Errorf("log format: unknown format: %s", format)
+	}
+	return nil
+}
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+var (
+	skipRegex    = regexp.MustCompile(`^[\w-]+(@[\w.]+)?$`)
+	releaseRegex = regexp.MustCompile(`^([\w-]+(?:@[\w.]+)?):([\w-]+)/([\w.-]+)$`)
+)
+
+// skipToMap parses a comma-separated list of foods. Every entry must be a
+// food name; all invalid entries are reported together.
+func skipToMap(skip string) (map[string]bool, error) {
+	m := map[string]bool{}
+	if len(skip) == 0 {
+		return m, nil
+	}
+
+	var invalid []string
+	for _, food := range strings.Split(strings.TrimSuffix(skip, ","), ",") {
+		food = strings.TrimSpace(food)
+		if !skipRegex.MatchString(food) {
+			invalid = append(invalid, strconv.Quote(food))
+			continue
+		}
+		m[food] = true
+	}
+	if len(invalid) > 0 {
+		return m, fmt.Errorf("validate skip: did not match spec `food`: %s", strings.Join(invalid, ", "))
+	}
+	return m, nil
+}
+
+// releaseToMap parses a comma-separated list of food:org/repo release
+// overrides. Every entry must match in full; all invalid entries are
+// reported together.
+func releaseToMap(release string) (map[string]source.GithubRelease, error) {
+	m := map[string]source.GithubRelease{}
+	if len(release) == 0 {
+		return m, nil
+	}
+
+	var invalid, duplicate []string
+	for _, food := range strings.Split(strings.TrimSuffix(release, ","), ",") {
+		food = strings.TrimSpace(food)
+		parts := releaseRegex.FindStringSubmatch(food)
+		if parts == nil {
+			invalid = append(invalid, strconv.Quote(food))
+			continue
+		}
+		r := source.GithubRelease{Org: parts[2], Repo: parts[3]}
+		if prev, ok := m[parts[1]]; ok && prev != r {
+			duplicate = append(duplicate, fmt.Sprintf("%s (%s/%s and %s/%s)", parts[1], prev.Org, prev.Repo, r.Org, r.Repo))
+			continue
+		}
+		m[parts[1]] = r
+	}
+	if len(invalid) > 0 {
+		return m, fmt.Errorf("validate release: did not match spec `food:org/repo`: %s", strings.Join(invalid, ", "))
+	}
+	if len(duplicate) > 0 {
+		return m, fmt.Errorf("validate release: foods released from more than one repository: %s", strings.Join(duplicate, ", "))
+	}
+	return m, nil
+}
+
+// emailToMap parses a comma-separated list of severity:address email
+// recipients. Every entry must name a known severity; all invalid entries are
+// reported together.
+func emailToMap(emailTo string) (map[string][]string, error) {
+	m := map[string][]string{}
+	if len(emailTo) == 0 {
+		return m, nil
+	}
+
+	var invalid []string
+	for _, entry := range strings.Split(strings.TrimSuffix(emailTo, ","), ",") {
+		entry = strings.TrimSpace(entry)
+		severity, addr, ok := strings.Cut(entry, ":")
+		switch severity {
+		case bump.SeverityError, bump.SeverityWarning, bump.SeverityInfo:
+		default:
+			ok = false
+		}
+		if !ok || !strings.Contains(addr, "@") {
+			invalid = append(invalid, strconv.Quote(entry))
+			continue
+		}
+		m[severity] = append(m[severity], addr)
+	}
+	if len(invalid) > 0 {
+		return m, fmt.Errorf("validate email-to: did not match spec `severity:address`: %s", strings.Join(invalid, ", "))
+	}
+	return m, nil
+}
+
+// checkSkipRelease returns an error if any food is both skipped and given a
+// release override, which suggests one of the two is a mistake.
+func checkSkipRelease(skip map[string]bool, release map[string]source.GithubRelease) error {
+	var both []string
+	for food := range release {
+		if skip[food] {
+			both = append(both, food)
+		}
+	}
+	if len(both) > 0 {
+		sort.Strings(both)
+		return fmt.Errorf("foods both skipped and given a release: %s", strings.Join(both, ", "))
+	}
+	return nil
+}
+
+// otlpEndpoint returns the OTLP traces endpoint set by the environment, as
+// for OpenTelemetry SDKs.
+func otlpEndpoint() string {
+	if u := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); len(u) > 0 {
+		return u
+	}
+	if u := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); len(u) > 0 {
+		return strings.TrimSuffix(u, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// otlpHeaders parses headers in the format of OTEL_EXPORTER_OTLP_HEADERS:
+// comma-separated key=value pairs with URL-encoded values.
+func otlpHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(v)); err == nil {
+			v = unescaped
+		}
+		headers[strings.TrimSpace(k)] = v
+	}
+	return headers
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/arbourd/gfb/pkg/bump"
+	"github.com/arbourd/gfb/pkg/rig"
+	"github.com/fishworks/gofish"
+	"github.com/google/go-github/v39/github"
+)
+
+// ServeOptions configures the long-running serve command.
+type ServeOptions struct {
+	// Interval is the time between scheduled runs.
+	Interval time.Duration
+	// Addr is the address to listen on for HTTP requests. The server is not
+	// started if Addr is empty.
+	Addr string
+	// WebhookSecret validates GitHub webhook deliveries. Webhooks are not
+	// accepted if it is empty.
+	WebhookSecret string
+	// AdminToken authorizes requests to the admin endpoints. They are not
+	// served if it is empty.
+	AdminToken string
+	// Config is the path of the config file reloaded on SIGHUP or a request
+	// to /reload, if set.
+	Config string
+}
+
+// server runs the bump pass on a schedule and in response to webhooks,
+// opening a pull request with the changes of each run.
+type server struct {
+	// base is the options before the config file was applied.
+	base  bump.Options
+	sopts ServeOptions
+
+	// optsMu guards opts, which are replaced when the config is reloaded.
+	optsMu sync.Mutex
+	opts   bump.Options
+
+	// mu serializes runs, so scheduled and triggered runs never work on the
+	// rig at the same time.
+	mu sync.Mutex
+
+	outdatedMu   sync.Mutex
+	outdated     []Staleness
+	outdatedTime time.Time
+
+	foodsMu   sync.Mutex
+	foods     map[string]bool
+	foodsTime time.Time
+
+	// queueMu guards the foods requested by /bump and the upstreams of
+	// releases delivered by webhooks. Requested foods are batched into a
+	// single run after the run in progress, and requests for foods already
+	// queued or running are merged into that run. Upstreams are batched into
+	// a run of their own, as a run limited to both foods and upstreams only
+	// processes foods in both.
+	queueMu         sync.Mutex
+	queued          map[string]bool
+	running         map[string]bool
+	queuedUpstreams map[string]bool
+	draining        bool
+
+	statusMu    sync.Mutex
+	lastRun     time.Time
+	lastSuccess time.Time
+	lastErr     error
+}
+
+// outdatedTTL is how long the staleness report served by /outdated is cached.
+const outdatedTTL = 10 * time.Minute
+
+// serve runs the bump pass every interval until ctx is cancelled. A random
+// jitter of up to a tenth of the interval is added to each sleep. The config
+// is reloaded onto base on SIGHUP; opts are the options it was first loaded
+// with.
+func serve(ctx context.Context, sopts ServeOptions, base, opts bump.Options) error {
+	base.OpenPR = true
+	opts.OpenPR = true
+	if opts.Metrics == nil {
+		opts.Metrics = bump.NewMetrics()
+	}
+	base.Metrics = opts.Metrics
+	s := &server{base: base, opts: opts, sopts: sopts}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := s.reload(); err != nil {
+					slog.Error("reloading config failed", "error", err)
+				}
+			}
+		}
+	}()
+
+	if len(sopts.Addr) > 0 {
+		srv := &http.Server{Addr: sopts.Addr, Handler: s.handler(ctx)}
+		go func() {
+			<-ctx.Done()
+			srv.Shutdown(context.Background())
+		}()
+		go func() {
+			slog.Info("listening", "addr", sopts.Addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("http server failed", "error", err)
+			}
+		}()
+	}
+
+	for {
+		s.run(ctx, s.options())
+
+		sleep := sopts.Interval
+		if jitter := int64(sopts.Interval / 10); jitter > 0 {
+			sleep += time.Duration(rand.Int63n(jitter))
+		}
+		slog.Info("sleeping until next run", "duration", sleep.Round(time.Second).String())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(sleep):
+		}
+	}
+}
+
+// options returns the options of the next run.
+func (s *server) options() bump.Options {
+	s.optsMu.Lock()
+	defer s.optsMu.Unlock()
+	return s.opts
+}
+
+// reload applies the config file to the base options, replacing the options
+// of later runs. Runs in progress and cached reports are not affected. The
+// options are left unchanged if the config is invalid.
+func (s *server) reload() error {
+	if len(s.sopts.Config) == 0 {
+		return errors.New("no config file to reload")
+	}
+
+	c, err := loadConfig(s.sopts.Config)
+	if err != nil {
+		return err
+	}
+	opts := s.base
+	err = c.apply(&opts)
+	if err != nil {
+		return err
+	}
+
+	s.optsMu.Lock()
+	s.opts = opts
+	s.optsMu.Unlock()
+
+	slog.Info("reloaded config", "path", s.sopts.Config, "skip", len(opts.Skip), "release", len(opts.Release), "constraints", len(opts.Constraints))
+	return nil
+}
+
+func (s *server) run(ctx context.Context, opts bump.Options) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, err := bump.New(bump.WithOptions(opts)).Run(ctx)
+
+	s.statusMu.Lock()
+	s.lastRun = time.Now()
+	s.lastErr = err
+	if err == nil {
+		s.lastSuccess = s.lastRun
+	}
+	s.statusMu.Unlock()
+
+	if err != nil {
+		slog.Error("run failed", "error", err)
+		return
+	}
+	slog.Info("run finished", "status", res.Status)
+}
+
+func (s *server) handler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	if len(s.sopts.WebhookSecret) > 0 {
+		mux.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
+			s.webhook(ctx, w, r)
+		})
+	}
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /readyz", s.readyHandler)
+	mux.Handle("GET /metrics", s.options().Metrics)
+	mux.HandleFunc("GET /outdated", s.outdatedHandler)
+	if len(s.sopts.AdminToken) > 0 {
+		mux.HandleFunc("POST /reload", s.admin(func(w http.ResponseWriter, r *http.Request) {
+			if err := s.reload(); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		mux.HandleFunc("POST /bump/{food}", s.admin(func(w http.ResponseWriter, r *http.Request) {
+			food := r.PathValue("food")
+			foods, err := s.foodNames(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !foods[food] {
+				http.Error(w, "no food named "+food, http.StatusNotFound)
+				return
+			}
+			s.enqueue(ctx, food)
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	}
+	return mux
+}
+
+// foodNames returns the names of the foods of the rig, cached for as long as
+// the staleness report.
+func (s *server) foodNames(ctx context.Context) (map[string]bool, error) {
+	s.foodsMu.Lock()
+	defer s.foodsMu.Unlock()
+
+	if time.Since(s.foodsTime) > outdatedTTL {
+		opts := s.options()
+		feed, cleanup, err := s.loadRig(ctx, &opts)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+
+		s.foods = map[string]bool{}
+		for _, f := range feed {
+			s.foods[f.Name] = true
+		}
+		s.foodsTime = time.Now()
+	}
+	return s.foods, nil
+}
+
+// loadRig loads the rig outside of runs. It is cloned into a temporary
+// directory rather than the workdir, which a run may be resetting.
+func (s *server) loadRig(ctx context.Context, opts *bump.Options) ([]gofish.Food, func(), error) {
+	opts.Workdir = ""
+	return bump.LoadRig(ctx, opts)
+}
+
+// enqueue queues a run of food, unless it is already queued or running.
+func (s *server) enqueue(ctx context.Context, food string) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if s.queued[food] || s.running[food] {
+		slog.Info("bump already requested", "food", food)
+		return
+	}
+	if s.queued == nil {
+		s.queued = map[string]bool{}
+	}
+	s.queued[food] = true
+	s.startDrain(ctx)
+}
+
+// enqueueUpstream queues a run of the foods released from upstream. Releases
+// delivered while the run is in progress are run again, as the run may have
+// resolved an earlier release.
+func (s *server) enqueueUpstream(ctx context.Context, upstream string) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if s.queuedUpstreams[upstream] {
+		slog.Info("release run already queued", "upstream", upstream)
+		return
+	}
+	if s.queuedUpstreams == nil {
+		s.queuedUpstreams = map[string]bool{}
+	}
+	s.queuedUpstreams[upstream] = true
+	s.startDrain(ctx)
+}
+
+// startDrain starts draining the queue unless it is already being drained.
+// queueMu must be held.
+func (s *server) startDrain(ctx context.Context) {
+	if !s.draining {
+		s.draining = true
+		go s.drain(ctx)
+	}
+}
+
+// drain runs the queued foods and upstreams in batches until none are left.
+func (s *server) drain(ctx context.Context) {
+	for {
+		s.queueMu.Lock()
+		batch, upstreams := s.queued, s.queuedUpstreams
+		s.queued, s.queuedUpstreams, s.running = nil, nil, batch
+		if (len(batch) == 0 && len(upstreams) == 0) || ctx.Err() != nil {
+			s.draining = false
+			s.queueMu.Unlock()
+			return
+		}
+		s.queueMu.Unlock()
+
+		if len(batch) > 0 {
+			opts := s.options()
+			opts.Only = batch
+			s.run(ctx, opts)
+
+			s.queueMu.Lock()
+			s.running = nil
+			s.queueMu.Unlock()
+		}
+		if len(upstreams) > 0 {
+			opts := s.options()
+			opts.Upstreams = upstreams
+			s.run(ctx, opts)
+		}
+	}
+}
+
+// admin wraps the handler of an admin endpoint, rejecting requests without
+// the admin token as a bearer token.
+func (s *server) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.sopts.AdminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// readyStatus is the state of the daemon reported by /readyz.
+type readyStatus struct {
+	LastRun     *time.Time   `json:"last_run,omitempty"`
+	LastSuccess *time.Time   `json:"last_success,omitempty"`
+	LastError   string       `json:"last_error,omitempty"`
+	Rig         string       `json:"rig"`
+	RateLimit   *github.Rate `json:"rate_limit,omitempty"`
+}
+
+// readyHandler responds with when runs last finished and succeeded, whether
+// the rig could be cloned, and the remaining GitHub rate limit. The daemon is
+// ready once a run has succeeded and the last run could clone the rig.
+func (s *server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	status := readyStatus{Rig: "ok"}
+
+	s.statusMu.Lock()
+	if !s.lastRun.IsZero() {
+		lastRun := s.lastRun
+		status.LastRun = &lastRun
+	}
+	if !s.lastSuccess.IsZero() {
+		lastSuccess := s.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+		if errors.Is(s.lastErr, rig.ErrClone) {
+			status.Rig = "clone failed"
+		}
+	}
+	s.statusMu.Unlock()
+
+	opts := s.options()
+	bump.SetupGithub(r.Context(), &opts)
+	if limits, _, err := opts.GithubClient.RateLimits(r.Context()); err == nil {
+		status.RateLimit = limits.Core
+	}
+
+	code := http.StatusOK
+	if status.LastSuccess == nil || status.Rig != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// outdatedHandler responds with the staleness report of the foods that are
+// behind their upstream.
+func (s *server) outdatedHandler(w http.ResponseWriter, r *http.Request) {
+	s.outdatedMu.Lock()
+	defer s.outdatedMu.Unlock()
+
+	if time.Since(s.outdatedTime) > outdatedTTL {
+		opts := s.options()
+		feed, cleanup, err := s.loadRig(r.Context(), &opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cleanup()
+
+		s.outdated = []Staleness{}
+		for _, row := range staleness(r.Context(), feed, opts) {
+			if row.Releases > 0 {
+				s.outdated = append(s.outdated, row)
+			}
+		}
+		s.outdatedTime = time.Now()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.outdated)
+}
+
+// webhook handles GitHub release events, processing only the foods released
+// from the repository that published the release.
+func (s *server) webhook(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := github.ValidatePayload(r, []byte(s.sopts.WebhookSecret))
+	if err != nil {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	release, ok := event.(*github.ReleaseEvent)
+	if !ok || release.GetAction() != "released" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	upstream := strings.ToLower(release.GetRepo().GetFullName())
+	slog.Info("release webhook", "upstream", upstream, "tag", release.GetRelease().GetTagName())
+
+	s.enqueueUpstream(ctx, upstream)
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/arbourd/gfb/pkg/bump"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+)
+
+// Stats are rig-level aggregates derived from the parsed feed.
+type Stats struct {
+	Foods      int
+	Resolvable int
+	Skipped    int
+	Pinned     int
+	// Platforms maps os/arch to the number of foods with a package for it.
+	Platforms map[string]int
+	Packages  int
+	Checksums int
+}
+
+func stats(ctx context.Context, opts bump.Options) error {
+	feed, cleanup, err := bump.LoadRig(ctx, &opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return printStats(rigStats(feed, opts))
+}
+
+func rigStats(feed []gofish.Food, opts bump.Options) Stats {
+	s := Stats{Foods: len(feed), Platforms: map[string]int{}}
+	for _, f := range feed {
+		if org, _ := source.GithubRepo(f, opts.Release); len(org) > 0 {
+			s.Resolvable++
+		}
+		if opts.Skip[f.Name] {
+			s.Skipped++
+		}
+		if _, pin := source.SplitPin(f.Name); len(pin) > 0 {
+			s.Pinned++
+		}
+
+		platforms := map[string]bool{}
+		for _, pkg := range f.Packages {
+			platforms[pkg.OS+"/"+pkg.Arch] = true
+			s.Packages++
+			if len(pkg.SHA256) > 0 {
+				s.Checksums++
+			}
+		}
+		for p := range platforms {
+			s.Platforms[p]++
+		}
+	}
+	return s
+}
+
+func printStats(s Stats) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "foods\t%d\n", s.Foods)
+	fmt.Fprintf(w, "github resolvable\t%d\t%s\n", s.Resolvable, percent(s.Resolvable, s.Foods))
+	fmt.Fprintf(w, "skipped\t%d\t%s\n", s.Skipped, percent(s.Skipped, s.Foods))
+	fmt.Fprintf(w, "pinned\t%d\t%s\n", s.Pinned, percent(s.Pinned, s.Foods))
+	fmt.Fprintf(w, "packages with checksums\t%d/%d\t%s\n", s.Checksums, s.Packages, percent(s.Checksums, s.Packages))
+
+	var platforms []string
+	for p := range s.Platforms {
+		platforms = append(platforms, p)
+	}
+	sort.Slice(platforms, func(i, j int) bool {
+		if s.Platforms[platforms[i]] != s.Platforms[platforms[j]] {
+			return s.Platforms[platforms[i]] > s.Platforms[platforms[j]]
+		}
+		return platforms[i] < platforms[j]
+	})
+	for _, p := range platforms {
+		fmt.Fprintf(w, "platform %s\t%d\t%s\n", p, s.Platforms[p], percent(s.Platforms[p], s.Foods))
+	}
+	return w.Flush()
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
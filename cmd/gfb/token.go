@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// tokenTimeout bounds each command run to resolve the GitHub token, so a
+// credential helper prompting for input cannot hang the run.
+const tokenTimeout = 5 * time.Second
+
+// resolveToken returns a GitHub token from the tooling the user is already
+// authenticated with: the gh CLI, the GITHUB_TOKEN environment variable, or
+// a git credential helper, in order. It returns an empty string if none has
+// a token.
+func resolveToken(ctx context.Context) string {
+	if out, err := runTokenCommand(ctx, nil, "gh", "auth", "token"); err == nil {
+		if token := strings.TrimSpace(out); len(token) > 0 {
+			slog.Debug("using github token", "source", "gh")
+			return token
+		}
+	}
+
+	if token := os.Getenv("GITHUB_TOKEN"); len(token) > 0 {
+		slog.Debug("using github token", "source", "GITHUB_TOKEN")
+		return token
+	}
+
+	input := strings.NewReader("protocol=https\nhost=github.com\n\n")
+	if out, err := runTokenCommand(ctx, input, "git", "credential", "fill"); err == nil {
+		s := bufio.NewScanner(strings.NewReader(out))
+		for s.Scan() {
+			if token, ok := strings.CutPrefix(s.Text(), "password="); ok && len(token) > 0 {
+				slog.Debug("using github token", "source", "git credential")
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+func runTokenCommand(ctx context.Context, stdin *strings.Reader, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	// Never prompt for credentials on the terminal.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GH_PROMPT_DISABLED=1")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	return out.String(), err
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// httpOptions configures the HTTP transport shared by the run.
+type httpOptions struct {
+	// Proxy is the URL of the proxy HTTP requests go through, if not the
+	// proxy of the environment.
+	Proxy string
+	// CABundle is the path of PEM certificates trusted besides the system
+	// roots, and ClientCert and ClientKey the paths of the PEM certificate
+	// and key presented to servers requesting one.
+	CABundle   string
+	ClientCert string
+	ClientKey  string
+	// MaxIdleConns and MaxIdleConnsPerHost bound the idle connections kept
+	// for reuse, and IdleConnTimeout how long they are kept. KeepAlive
+	// reuses connections between requests, and HTTP2 negotiates HTTP/2 with
+	// servers supporting it.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	KeepAlive           bool
+	HTTP2               bool
+	// DownloadHeaders maps hosts to headers sent with HTTPS requests to them,
+	// for package downloads from gated hosts.
+	DownloadHeaders map[string]map[string]string
+	// GithubToken downloads release assets of private repositories.
+	GithubToken string
+}
+
+// setupHTTP creates the HTTP client shared by GitHub API calls, package
+// downloads and git. Requests go through the proxy if one is set, or else
+// the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables. Servers are verified with the CA bundle as well as the system
+// roots, for mirrors and proxies with internal certificates. Release assets
+// of private GitHub repositories are downloaded with the GitHub token.
+// Connections are pooled as set in the options, so concurrent downloads from
+// the same host reuse them.
+func setupHTTP(opts httpOptions) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	t.MaxIdleConns = opts.MaxIdleConns
+	t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	t.IdleConnTimeout = opts.IdleConnTimeout
+	t.DisableKeepAlives = !opts.KeepAlive
+	if !opts.HTTP2 {
+		// A non-nil empty map disables HTTP/2.
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if len(opts.Proxy) > 0 {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsConfig
+	var rt http.RoundTripper = t
+	if len(opts.DownloadHeaders) > 0 {
+		rt = headerTransport{base: t, headers: opts.DownloadHeaders}
+	}
+	if len(opts.GithubToken) > 0 {
+		rt = assetTransport{base: rt, token: opts.GithubToken}
+	}
+	c := &http.Client{Transport: rt}
+
+	// gofish downloads packages to lint foods with the default client.
+	http.DefaultClient.Transport = rt
+	client.InstallProtocol("http", githttp.NewClient(c))
+	client.InstallProtocol("https", githttp.NewClient(c))
+	return c, nil
+}
+
+// tlsConfig returns the TLS configuration of the shared transport: the
+// system roots and the CA bundle, and the client certificate if one is set.
+func tlsConfig(opts httpOptions) (*tls.Config, error) {
+	c := &tls.Config{}
+
+	if len(opts.CABundle) > 0 {
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("ca bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca bundle: no certificates in %s", opts.CABundle)
+		}
+		c.RootCAs = pool
+	}
+
+	if len(opts.ClientCert) > 0 || len(opts.ClientKey) > 0 {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+// headerTransport adds the headers of the request's host to HTTPS requests.
+// Headers are added to each request sent rather than the request made, so
+// they are not carried over redirects to other hosts.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]map[string]string
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	headers, ok := t.headers[strings.ToLower(req.URL.Hostname())]
+	if !ok || req.URL.Scheme != "https" {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(req)
+}
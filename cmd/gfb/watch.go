@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/arbourd/gfb/pkg/bump"
+	"github.com/arbourd/gfb/pkg/rig"
+)
+
+// watch polls the rig branch every interval and, when new commits
+// land, runs the bump pass on only the foods they added or modified. Runs
+// that fail are retried at the next poll.
+func watch(ctx context.Context, interval time.Duration, opts bump.Options) error {
+	bump.SetupGithub(ctx, &opts)
+	org, repo, err := rig.Repo(opts.RigOptions())
+	if err != nil {
+		return err
+	}
+
+	ref := "HEAD"
+	if len(opts.RigBranch) > 0 {
+		ref = opts.RigBranch
+	}
+
+	last, _, err := opts.GithubClient.Repositories.GetCommitSHA1(ctx, org, repo, ref, "")
+	if err != nil {
+		return fmt.Errorf("resolving rig head: %w", err)
+	}
+	slog.Info("watching rig", "rig", rig.URL(opts.RigOptions()), "head", last)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+
+		head, _, err := opts.GithubClient.Repositories.GetCommitSHA1(ctx, org, repo, ref, last)
+		if err != nil {
+			slog.Error("resolving rig head failed", "error", err)
+			continue
+		}
+		if len(head) == 0 || head == last {
+			continue
+		}
+
+		foods, err := changedFoods(ctx, org, repo, last, head, opts)
+		if err != nil {
+			slog.Error("comparing rig commits failed", "base", last, "head", head, "error", err)
+			continue
+		}
+		if len(foods) == 0 {
+			last = head
+			continue
+		}
+
+		slog.Info("rig changed", "head", head, "foods", len(foods))
+		runOpts := opts
+		runOpts.Only = foods
+		res, err := bump.New(bump.WithOptions(runOpts)).Run(ctx)
+		if err != nil {
+			slog.Error("run failed", "error", err)
+			continue
+		}
+		slog.Info("run finished", "status", res.Status)
+		// Foods that failed transiently are run again at the next poll;
+		// others are not run again until they change.
+		if res.Status != bump.ExitTransient {
+			last = head
+		}
+	}
+}
+
+// changedFoods returns the names of foods added or modified between the base
+// and head commits of the rig.
+func changedFoods(ctx context.Context, org, repo, base, head string, opts bump.Options) (map[string]bool, error) {
+	comparison, _, err := opts.GithubClient.Repositories.CompareCommits(ctx, org, repo, base, head, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	foods := map[string]bool{}
+	for _, file := range comparison.Files {
+		name := file.GetFilename()
+		if file.GetStatus() == "removed" || !rig.InFoodDir(name, opts.FoodDir) {
+			continue
+		}
+		foods[strings.TrimSuffix(path.Base(name), ".lua")] = true
+	}
+	return foods, nil
+}
@@ -0,0 +1,219 @@
+// Package trace records spans of the phases of a run and exports them to an
+// OpenTelemetry collector with OTLP over HTTP, in its JSON encoding.
+package trace
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Tracer collects the spans of a run until they are exported.
+type Tracer struct {
+	// Endpoint is the URL of the OTLP traces endpoint, such as
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+	// Headers are sent with each export, such as to authenticate to the
+	// collector.
+	Headers map[string]string
+	Service string
+	Client  *http.Client
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Span is a timed phase of a run. The methods of a nil Span do nothing, so
+// code is traced the same way whether or not a tracer is set.
+type Span struct {
+	tracer  *Tracer
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	name    string
+	start   time.Time
+	end     time.Time
+	attrs   []attr
+	err     string
+}
+
+type attr struct {
+	key, value string
+}
+
+type spanKey struct{}
+type tracerKey struct{}
+
+// WithTracer returns ctx carrying t, which spans started from it are recorded
+// by.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Start starts a span named name, the child of the span of ctx if it has one,
+// with attrs as key-value pairs. It returns ctx carrying the span. The span is
+// nil if ctx carries no tracer.
+func Start(ctx context.Context, name string, attrs ...string) (context.Context, *Span) {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, start: time.Now()}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.traceID, s.parent = parent.traceID, parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.SetAttr(attrs[i], attrs[i+1])
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttr sets the attribute key of s to value.
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attr{key, value})
+}
+
+// End ends s, marking it failed with err if it is not nil.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, s)
+	s.tracer.mu.Unlock()
+}
+
+// Flush exports the spans ended since the last flush.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.export(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("exporting %d spans to %s\n\nresponse code: %v", len(spans), t.Endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding of spans. IDs are hex, and times are nanoseconds
+// since the epoch as strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID      string     `json:"traceId"`
+		SpanID       string     `json:"spanId"`
+		ParentSpanID string     `json:"parentSpanId,omitempty"`
+		Name         string     `json:"name"`
+		Kind         int        `json:"kind"`
+		Start        string     `json:"startTimeUnixNano"`
+		End          string     `json:"endTimeUnixNano"`
+		Attributes   []otlpAttr `json:"attributes,omitempty"`
+		Status       otlpStatus `json:"status"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+const (
+	spanKindInternal = 1
+	statusError      = 2
+)
+
+func (t *Tracer) export(spans []*Span) otlpRequest {
+	service := t.Service
+	if len(service) == 0 {
+		service = "gfb"
+	}
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/arbourd/gfb"}}
+	for _, s := range spans {
+		out := otlpSpan{
+			TraceID: hex.EncodeToString(s.traceID[:]),
+			SpanID:  hex.EncodeToString(s.spanID[:]),
+			Name:    s.name,
+			Kind:    spanKindInternal,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			out.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			out.Attributes = append(out.Attributes, otlpAttr{Key: a.key, Value: otlpValue{StringValue: a.value}})
+		}
+		if len(s.err) > 0 {
+			out.Status = otlpStatus{Code: statusError, Message: s.err}
+		}
+		scope.Spans = append(scope.Spans, out)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttr{{Key: "service.name", Value: otlpValue{StringValue: service}}}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
@@ -0,0 +1,59 @@
+// Package transient classifies errors that may not recur if the operation
+// failing with them is retried.
+package transient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// Error marks an error that may not recur if the operation is retried.
+type Error struct {
+	Err error
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether err is caused by the network, rate limiting or an
+// upstream server error, rather than a problem with the food or its data.
+func Is(err error) bool {
+	var te Error
+	if errors.As(err, &te) {
+		return true
+	}
+
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return true
+	}
+
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil {
+		return Status(errResp.Response.StatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Status reports whether an HTTP response status may not recur if the
+// request is retried.
+func Status(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
@@ -0,0 +1,164 @@
+package bump
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// inActions reports whether gfb is running in a GitHub Actions workflow.
+func inActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// Workflow commands are written to stderr with the logs, leaving stdout to
+// reports such as -report-json -.
+
+// startGroup starts a collapsible group of log lines in the workflow log.
+func startGroup(name string) {
+	fmt.Fprintf(os.Stderr, "::group::%s\n", escapeData(name))
+}
+
+func endGroup() {
+	fmt.Fprintln(os.Stderr, "::endgroup::")
+}
+
+// annotate emits a workflow command annotating the food's file with the
+// outcome of processing it, if the result is a failure or a skip.
+func annotate(res Result, opts Options) {
+	var level string
+	switch res.Action {
+	case ActionError:
+		level = "error"
+	case ActionSkipped, ActionNeedsHuman:
+		level = "warning"
+	default:
+		return
+	}
+	message := res.Detail()
+
+	file := foodFileURI(res.Food, opts)
+	fmt.Fprintf(os.Stderr, "::%s file=%s,title=%s::%s\n", level, escapeProperty(file), escapeProperty(res.Food), escapeData(message))
+}
+
+// foodFileURI returns the slash-separated path of the food's file relative to
+// the root of the rig.
+func foodFileURI(food string, opts Options) string {
+	return opts.rig.Rel(opts.rig.FoodFile(food))
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// writeStepSummary appends a Markdown summary of the report to the GitHub
+// Actions step summary file at path.
+func writeStepSummary(path string, r *Report) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString("## gfb\n\n" + markdownSummary(r))
+	return err
+}
+
+// writeOutputs appends the outputs of the step to the GitHub Actions output
+// file at path, for later steps and jobs of the workflow:
+//
+//   - updated_count: the number of foods updated
+//   - updated_foods: the comma-separated names of the foods updated
+//   - failed_count: the number of foods that failed
+//   - pull_request: the URL of the pull request opened, if any
+//   - report_path: the absolute path of the JSON report, if one was written
+func writeOutputs(path string, r *Report, pullRequest string, opts Options) error {
+	r.mu.Lock()
+	var updated []string
+	failed := 0
+	for _, res := range r.Results {
+		switch res.Action {
+		case ActionUpdated:
+			updated = append(updated, res.Food)
+		case ActionError:
+			failed++
+		}
+	}
+	r.mu.Unlock()
+
+	var reportPath string
+	if len(opts.ReportJSON) > 0 {
+		var err error
+		reportPath, err = filepath.Abs(opts.ReportJSON)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, o := range []struct{ name, value string }{
+		{"updated_count", strconv.Itoa(len(updated))},
+		{"updated_foods", strings.Join(updated, ",")},
+		{"failed_count", strconv.Itoa(failed)},
+		{"pull_request", pullRequest},
+		{"report_path", reportPath},
+	} {
+		_, err := fmt.Fprintf(f, "%s=%s\n", o.name, strings.ReplaceAll(o.value, "\n", " "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownSummary returns the number of foods with each outcome, and a table
+// of the foods that were not up to date.
+func markdownSummary(r *Report) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := map[string]int{}
+	var b strings.Builder
+	b.WriteString("| Food | Result | Version | Details |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, res := range r.Results {
+		counts[res.Action]++
+
+		var version, details string
+		switch res.Action {
+		case ActionUpToDate:
+			continue
+		case ActionUpdated:
+			version = res.OldVersion + " → " + res.NewVersion
+		case ActionSkipped, ActionError, ActionNeedsHuman:
+			version = res.OldVersion
+			details = res.Detail()
+		case ActionNotAttempted:
+			version = res.OldVersion
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", res.Food, res.Action, version, markdownCell(details))
+	}
+
+	summary := fmt.Sprintf("%d updated, %d up to date, %d skipped, %d failed, %d need a human, %d not attempted\n\n",
+		counts[ActionUpdated], counts[ActionUpToDate], counts[ActionSkipped], counts[ActionError], counts[ActionNeedsHuman], counts[ActionNotAttempted])
+	if counts[ActionUpdated]+counts[ActionSkipped]+counts[ActionError]+counts[ActionNeedsHuman]+counts[ActionNotAttempted] > 0 {
+		summary += b.String()
+	}
+	return summary
+}
+
+// markdownCell escapes s for use inside a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(s)
+}
@@ -0,0 +1,118 @@
+package bump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// alertFindings maps the kinds of finding that suggest the supply chain of a
+// food was tampered with, which are alerted on as well as reported, to the
+// titles of their alerts.
+var alertFindings = map[string]string{
+	FindingChecksumDrift:    "package changed without a new version",
+	FindingChecksumMismatch: "checksum mismatch",
+}
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint alerts are sent
+// to when -alert-webhook is "pagerduty".
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// sendAlerts raises an alert on opts.AlertWebhook for each supply-chain
+// finding in r, keyed by the rig, food and kind of finding so that repeated
+// runs update the open alert rather than raise another. Unlike routine
+// notifications, alerts are sent for dry runs and plans too. Failing to alert
+// is logged, not returned, so it never fails the run.
+func sendAlerts(ctx context.Context, r *Report, opts Options) {
+	if len(opts.AlertWebhook) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	var findings []Finding
+	for _, f := range r.Findings {
+		if _, ok := alertFindings[f.Kind]; ok {
+			findings = append(findings, f)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, f := range findings {
+		if err := postAlert(ctx, f, opts); err != nil {
+			slog.Error("alerting failed", "food", f.Food, "phase", "notify", "host", webhookHost(alertURL(opts.AlertWebhook)), "kind", f.Kind, "error", err)
+			continue
+		}
+		slog.Warn("raised alert", "food", f.Food, "phase", "notify", "kind", f.Kind)
+	}
+}
+
+// postAlert raises an alert for f with the Opsgenie Alert API if
+// opts.AlertWebhook is an Opsgenie URL, and with the PagerDuty Events API v2
+// otherwise. opts.AlertKey is the Opsgenie API key or the PagerDuty routing
+// key.
+func postAlert(ctx context.Context, f Finding, opts Options) error {
+	hook := alertURL(opts.AlertWebhook)
+	key := fmt.Sprintf("gfb/%s/%s/%s", opts.Rig, f.Food, f.Kind)
+	summary := fmt.Sprintf("gfb: %s: %s on %s", f.Food, alertFindings[f.Kind], opts.Rig)
+	details := map[string]string{"rig": opts.Rig, "food": f.Food, "kind": f.Kind, "message": f.Message}
+
+	var payload any
+	header := http.Header{}
+	if isOpsgenieWebhook(hook) {
+		payload = map[string]any{
+			"message":     truncate(summary, 130),
+			"alias":       key,
+			"description": f.Message,
+			"priority":    "P1",
+			"source":      "gfb",
+			"tags":        []string{"gfb", "supply-chain"},
+			"details":     details,
+		}
+		header.Set("Authorization", "GenieKey "+opts.AlertKey)
+	} else {
+		payload = map[string]any{
+			"routing_key":  opts.AlertKey,
+			"event_action": "trigger",
+			"dedup_key":    key,
+			"payload": map[string]any{
+				"summary":        truncate(summary, 1024),
+				"source":         opts.Rig,
+				"severity":       "critical",
+				"component":      f.Food,
+				"class":          f.Kind,
+				"custom_details": details,
+			},
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(ctx, httpClient(opts), hook, body, header)
+}
+
+// alertURL returns the URL of hook, which may be "pagerduty" for the
+// PagerDuty Events API.
+func alertURL(hook string) string {
+	if hook == "pagerduty" {
+		return pagerDutyEventsURL
+	}
+	return hook
+}
+
+// isOpsgenieWebhook reports whether hook is the URL of the Opsgenie API.
+func isOpsgenieWebhook(hook string) bool {
+	host := webhookHost(hook)
+	return host == "opsgenie.com" || strings.HasSuffix(host, ".opsgenie.com")
+}
+
+// truncate returns s cut to at most n runes.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
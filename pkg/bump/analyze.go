@@ -0,0 +1,139 @@
+package bump
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+)
+
+// claim is a food laying claim to a shared resource, like an upstream
+// repository or an install path.
+type claim struct {
+	Food string
+	Path string
+}
+
+// analyzeRig reports foods that conflict with each other: foods tracking the
+// same upstream, shipping the same binary, or installing to the same path.
+// Pinned foods are grouped with the food they pin, as they conflict by design.
+func analyzeRig(feed []gofish.Food, opts Options) {
+	upstreams := map[string][]claim{}
+	binaries := map[string][]claim{}
+	installPaths := map[string][]claim{}
+
+	for _, f := range feed {
+		name, _ := source.SplitPin(f.Name)
+
+		if org, repo := source.GithubRepo(f, opts.Release); len(org) > 0 {
+			upstream := strings.ToLower(org + "/" + repo)
+			upstreams[upstream] = addClaim(upstreams[upstream], claim{Food: name})
+		}
+
+		for _, pkg := range f.Packages {
+			platform := pkg.OS + "/" + pkg.Arch
+			for _, r := range pkg.Resources {
+				p := platform + ": " + r.InstallPath
+				installPaths[p] = addClaim(installPaths[p], claim{Food: name})
+				if r.Executable {
+					b := platform + ": " + path.Base(r.InstallPath)
+					binaries[b] = addClaim(binaries[b], claim{Food: name, Path: r.InstallPath})
+				}
+			}
+		}
+	}
+
+	for _, upstream := range conflicts(upstreams) {
+		for _, c := range upstreams[upstream] {
+			opts.Report.Add(c.Food, FindingDuplicateUpstream, fmt.Sprintf("%s is shared with %s", upstream, others(upstreams[upstream], c.Food)))
+		}
+	}
+	for _, p := range conflicts(installPaths) {
+		for _, c := range installPaths[p] {
+			opts.Report.Add(c.Food, FindingConflictingPath, fmt.Sprintf("%s is shared with %s", p, others(installPaths[p], c.Food)))
+		}
+	}
+	for _, b := range conflicts(binaries) {
+		// Binaries installed to the same path are already reported as conflicting paths.
+		paths := map[string]bool{}
+		for _, c := range binaries[b] {
+			paths[c.Path] = true
+		}
+		if len(paths) == 1 {
+			continue
+		}
+		for _, c := range binaries[b] {
+			opts.Report.Add(c.Food, FindingDuplicateBinary, fmt.Sprintf("%s is shared with %s", b, others(binaries[b], c.Food)))
+		}
+	}
+}
+
+// urlVersionRegex matches versions embedded in package URLs.
+var urlVersionRegex = regexp.MustCompile(`\d+\.\d+(\.\d+)*`)
+
+// urlVersion returns the version embedded in url if it is not version: the
+// URL was left behind by an earlier bump, and replacing the version in it
+// would not change it. It returns false if url embeds version or no
+// version at all.
+func urlVersion(url, version string) (string, bool) {
+	if len(version) == 0 || len(versionIndexes(url, version)) > 0 {
+		return "", false
+	}
+	v := urlVersionRegex.FindString(url)
+	return v, len(v) > 0
+}
+
+// lintURLVersions reports packages whose URLs embed a version other than
+// the version of their food, unless they are released apart from it.
+func lintURLVersions(feed []gofish.Food, opts Options) {
+	for _, f := range feed {
+		for _, pkg := range f.Packages {
+			if _, _, _, own := ownUpstream(f, pkg, opts); own {
+				continue
+			}
+			if v, ok := urlVersion(pkg.URL, f.Version); ok {
+				opts.Report.Add(f.Name, FindingVersionMismatch, fmt.Sprintf("%s/%s: URL has version %s, not %s: %s", pkg.OS, pkg.Arch, v, f.Version, pkg.URL))
+			}
+		}
+	}
+}
+
+// conflicts returns the sorted keys of m claimed by more than one food.
+func conflicts(m map[string][]claim) []string {
+	var keys []string
+	for k, claims := range m {
+		if len(claims) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func others(claims []claim, food string) string {
+	var o []string
+	for _, c := range claims {
+		if c.Food == food {
+			continue
+		}
+		if len(c.Path) > 0 {
+			o = append(o, c.Food+" ("+c.Path+")")
+		} else {
+			o = append(o, c.Food)
+		}
+	}
+	return strings.Join(o, ", ")
+}
+
+func addClaim(claims []claim, c claim) []claim {
+	for _, e := range claims {
+		if e.Food == c.Food {
+			return claims
+		}
+	}
+	return append(claims, c)
+}
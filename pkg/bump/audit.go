@@ -0,0 +1,91 @@
+package bump
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// AuditEntry is a line of the audit log, recording a change applied to a food.
+type AuditEntry struct {
+	Time       time.Time       `json:"time"`
+	Food       string          `json:"food"`
+	OldVersion string          `json:"old_version"`
+	NewVersion string          `json:"new_version"`
+	Packages   []PackageChange `json:"packages"`
+	ReleaseURL string          `json:"release_url"`
+	Actor      string          `json:"actor"`
+	// PrevHash is the SHA-256 of the previous line of the log. Chaining the
+	// entries makes edits to or removal of earlier entries detectable.
+	PrevHash string `json:"prev_hash"`
+}
+
+// appendAudit appends an entry for the applied result res to the JSON Lines
+// audit log at path.
+func appendAudit(path string, res Result) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+
+	prev, err := lastLine(f)
+	if err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+	var prevHash string
+	if len(prev) > 0 {
+		prevHash = fmt.Sprintf("%x", sha256.Sum256(prev))
+	}
+
+	entry := AuditEntry{
+		Time:       time.Now().UTC(),
+		Food:       res.Food,
+		OldVersion: res.OldVersion,
+		NewVersion: res.NewVersion,
+		Packages:   res.Packages,
+		ReleaseURL: res.ReleaseURL,
+		Actor:      actor(),
+		PrevHash:   prevHash,
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, info.Size())
+	if _, err := f.ReadAt(b, 0); err != nil {
+		return nil, err
+	}
+
+	b = bytes.TrimRight(b, "\n")
+	return b[bytes.LastIndexByte(b, '\n')+1:], nil
+}
+
+// actor returns who is running gfb: the GitHub Actions actor if set,
+// otherwise the current user.
+func actor() string {
+	if a := os.Getenv("GITHUB_ACTOR"); len(a) > 0 {
+		return a
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
@@ -0,0 +1,986 @@
+// Package bump bumps the foods of a fish food rig to the latest releases of
+// their upstreams, reporting on the foods that need a maintainer's attention.
+package bump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/arbourd/gfb/internal/trace"
+	"github.com/arbourd/gfb/internal/transient"
+	"github.com/arbourd/gfb/pkg/checksum"
+	"github.com/arbourd/gfb/pkg/rig"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+	"github.com/google/go-github/v39/github"
+	"github.com/spf13/afero"
+	"golang.org/x/oauth2"
+)
+
+// Options configures a run.
+type Options struct {
+	Rig     string
+	Skip    map[string]bool
+	Release map[string]source.GithubRelease
+
+	AuthorName  string
+	AuthorEmail string
+
+	GithubAuthToken string
+	// RigToken authenticates cloning and pushing to rigs over HTTPS, if it
+	// differs from the GitHub token.
+	RigToken string
+
+	SyncDescription bool
+	OpenIssues      bool
+	HoldMajor       bool
+	// FailureIssues opens an issue on the rig for each food that could not
+	// be bumped in that many consecutive runs, such as because its upstream
+	// is gone or tags releases in a scheme gfb cannot parse, and closes it
+	// once the food is bumped. It requires State, and is disabled if 0.
+	FailureIssues int
+	// VerifyChecksums recomputes the checksums of foods that are up to date,
+	// reporting packages whose files were replaced without a new version.
+	VerifyChecksums bool
+	// AllowDowngrade bumps foods to upstream releases older than them, such
+	// as when the upstream deleted its latest release. Downgrades are
+	// reported and skipped otherwise.
+	AllowDowngrade bool
+	// ReplaceYanked bumps foods whose release was deleted upstream to the
+	// newest release left, even if it is older. Foods whose release was
+	// deleted are reported and need a human otherwise.
+	ReplaceYanked bool
+	PinMajor      bool
+	CheckURLs     bool
+	// SandboxInstall installs the package of each updated food for the
+	// current platform into a temporary prefix before writing it, and runs
+	// the test function of the food if it defines one. Foods whose package
+	// fails to download, unpack or link, or that fail their test, are
+	// skipped.
+	SandboxInstall bool
+	// SmokeTest runs the executables of each food installed in the sandbox
+	// with --version, then version, skipping foods none of whose
+	// executables print the new version. It implies SandboxInstall.
+	SmokeTest bool
+	// GofishVersion is the oldest gofish release the foods of the rig must
+	// parse with, such as 0.14.0. Updated foods setting fields it does not
+	// read, which it silently drops, are skipped. Foods are not checked if it
+	// is empty.
+	GofishVersion string
+
+	// CheckRun publishes the report of each run as a check run on the head
+	// commit of the rig: the pull request or commit that triggered the
+	// workflow in GitHub Actions, or the commit loaded otherwise.
+	CheckRun bool
+
+	ReportJSON  string
+	DryRun      bool
+	Diff        bool
+	AuditLog    string
+	ReportSARIF string
+	FailFast    bool
+	MaxErrors   int
+	Retry       bool
+	RetryDelay  time.Duration
+	Timeout     time.Duration
+	FoodTimeout time.Duration
+	OpenPR      bool
+	// NotifyWebhooks are the URLs of Slack and Discord incoming webhooks
+	// posted a summary of each run that updated or failed foods.
+	NotifyWebhooks []string
+	// ReportWebhook is the URL the JSON report of each run is posted to,
+	// signed with ReportWebhookSecret in SignatureHeader if it is set.
+	ReportWebhook       string
+	ReportWebhookSecret string
+	// AlertWebhook is the PagerDuty Events API or Opsgenie Alert API URL
+	// alerted to supply-chain findings, such as checksum drift, with
+	// AlertKey. It may be "pagerduty" for the PagerDuty Events API.
+	AlertWebhook string
+	AlertKey     string
+	// SMTPAddr is the host:port of the SMTP server emailing the report of
+	// each run from SMTPFrom, authenticating as SMTPUser if it is set.
+	SMTPAddr     string
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+	// EmailTo maps severities of runs to the addresses emailed the reports
+	// of runs at least that severe.
+	EmailTo map[string][]string
+	// Metrics records the metrics of each run, pushed to the Prometheus
+	// Pushgateway at Pushgateway after the run if it is set.
+	Metrics     *Metrics
+	Pushgateway string
+	// OTLPEndpoint is the URL of the OTLP/HTTP traces endpoint of an
+	// OpenTelemetry collector each run exports spans of its phases to, with
+	// OTLPHeaders. Runs are not traced if it is empty.
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+	// StatsD is sent the timing and outcome of each food, if set.
+	StatsD *StatsD
+	// LockTTL is how long the lock on the rig taken by runs opening pull
+	// requests is held before other runs may break it.
+	LockTTL time.Duration
+	// Only limits the run to the foods with these names, if set.
+	Only map[string]bool
+	// Upstreams limits the run to foods released from these lowercase
+	// org/repo GitHub repositories, if set.
+	Upstreams map[string]bool
+	// Constraints maps foods to a semver constraint that releases must
+	// satisfy to be bumped to.
+	Constraints map[string]string
+	// Channels maps foods to the release channel they track: stable, rc or
+	// nightly. Foods not in it track stable releases.
+	Channels map[string]string
+	// Mirrors rewrite package URLs when downloading them.
+	Mirrors []checksum.Mirror
+	// Sources resolve the releases of foods, tried in order before GitHub.
+	Sources []source.Source
+	// Checksums maps foods to the name of the provider their checksums are
+	// taken from before downloading their packages: one of the built-in
+	// providers or of ChecksumProviders.
+	Checksums map[string]string
+	// ChecksumProviders are providers of checksums foods may use, by name.
+	ChecksumProviders map[string]checksum.Provider
+	// ObjectChecksums takes the checksums of packages hosted on S3 or GCS
+	// from the object store, when it publishes them, instead of downloading
+	// the packages.
+	ObjectChecksums bool
+
+	// HTTPClient downloads packages and talks to GitHub and the forge
+	// hosting the rig. The default client is used if it is nil.
+	HTTPClient *http.Client
+	// Fs holds local checkouts of the rig, read and written by the run. The
+	// OS filesystem is used if it is nil.
+	Fs           afero.Fs
+	GithubClient *github.Client
+	Report       *Report
+	// Plan records the changes of the run instead of applying them, if set.
+	Plan *Plan
+	// Hooks are called while processing each food.
+	Hooks Hooks
+	// Events is called with the events of the run as they happen, if set.
+	Events func(Event)
+	// Updaters apply the changes of the run to targets other than the rig,
+	// after they are written to it. Plans are applied to them by Apply.
+	// Updaters that are Flushers are flushed once all changes are made.
+	Updaters []Updater
+	// RigHost is the kind of forge hosting the rig: github, gitlab or gitea.
+	// It is detected from the rig URL if empty.
+	RigHost string
+	// SSHKey is the path of the private key authenticating to rigs cloned
+	// over SSH. The SSH agent is used if it is empty.
+	SSHKey string
+	// RigBranch is the branch of the rig to clone and open pull requests
+	// against, instead of its default branch.
+	RigBranch string
+	// API reads the rig and commits changes to it through the GitHub API,
+	// instead of cloning it with git.
+	API bool
+	// FoodDir is the directory of the rig holding its foods, relative to its
+	// root. It is detected when the rig is loaded if empty.
+	FoodDir string
+	// Workdir is where the rig is cloned and kept between runs, if set.
+	Workdir string
+	// DiffRange limits the run to the foods added or modified in a git range
+	// of a local checkout of the rig, such as origin/main...HEAD.
+	DiffRange string
+	// State persists what runs saw of each food between runs, if set.
+	State *State
+	// SinceLastRun skips foods the state confirms were up to date within
+	// Freshness, without querying their upstream.
+	SinceLastRun bool
+	Freshness    time.Duration
+
+	// rig is the rig loaded by the run.
+	rig *rig.Rig
+}
+
+// RigOptions returns the options reading and changing the rig.
+func (o Options) RigOptions() rig.Options {
+	return rig.Options{
+		URL:          o.Rig,
+		Branch:       o.RigBranch,
+		Host:         o.RigHost,
+		SSHKey:       o.SSHKey,
+		Token:        o.RigToken,
+		GithubToken:  o.GithubAuthToken,
+		API:          o.API,
+		FoodDir:      o.FoodDir,
+		Workdir:      o.Workdir,
+		DiffRange:    o.DiffRange,
+		AuthorName:   o.AuthorName,
+		AuthorEmail:  o.AuthorEmail,
+		GithubClient: o.GithubClient,
+		HTTPClient:   o.HTTPClient,
+		Fs:           o.Fs,
+	}
+}
+
+// httpClient returns the HTTP client of the run.
+func httpClient(opts Options) *http.Client {
+	if opts.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return opts.HTTPClient
+}
+
+// SetupGithub creates the GitHub client used by the run. Its requests are
+// counted in opts.Metrics, if set.
+func SetupGithub(ctx context.Context, opts *Options) {
+	client := httpClient(*opts)
+	if opts.Metrics != nil {
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		counted := *client
+		counted.Transport = countingTransport{base: base, metrics: opts.Metrics}
+		client = &counted
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+	opts.GithubClient = github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: opts.GithubAuthToken})))
+}
+
+// LoadRig loads the rig and parses its foods, starting a new report. The
+// returned function removes the clone of the rig.
+func LoadRig(ctx context.Context, opts *Options) ([]gofish.Food, func(), error) {
+	SetupGithub(ctx, opts)
+	opts.Report = &Report{}
+
+	r, feed, cleanup, err := rig.Load(ctx, opts.RigOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+	opts.rig = r
+	return feed, cleanup, nil
+}
+
+// sources returns the sources resolving releases for the run, ending with
+// GitHub.
+func sources(opts Options) []source.Source {
+	github := &source.Github{Client: opts.GithubClient, Release: opts.Release, Constraints: opts.Constraints, Channels: opts.Channels, HTTPClient: httpClient(opts)}
+	return append(opts.Sources[:len(opts.Sources):len(opts.Sources)], github)
+}
+
+// checksumProviders returns the providers of checksums of the run by name.
+func checksumProviders(opts Options) map[string]checksum.Provider {
+	providers := map[string]checksum.Provider{
+		checksum.ProviderDownload:      checksum.Downloader{Client: httpClient(opts), Mirrors: opts.Mirrors},
+		checksum.ProviderChecksumsFile: checksum.ChecksumsFile{Client: httpClient(opts), Mirrors: opts.Mirrors},
+		checksum.ProviderGithubDigest:  checksum.GithubDigest{Client: opts.GithubClient},
+		checksum.ProviderObjectStore:   checksum.ObjectStore{Client: httpClient(opts), Mirrors: opts.Mirrors},
+	}
+	for name, p := range opts.ChecksumProviders {
+		providers[name] = p
+	}
+	return providers
+}
+
+// packageChecksum returns the checksum of pkg of f, the name of the provider
+// it was taken from and the number of bytes downloaded to get it. The
+// provider selected for f is tried first, then the object store if enabled,
+// and the package is downloaded if neither has its checksum.
+func packageChecksum(ctx context.Context, f gofish.Food, pkg checksum.Package, opts Options) (string, string, int64, error) {
+	ctx, span := trace.Start(ctx, "checksum", "url", pkg.URL)
+	sha, provider, n, err := providerChecksum(ctx, f, pkg, opts)
+	span.SetAttr("provider", provider)
+	span.End(err)
+	return sha, provider, n, err
+}
+
+func providerChecksum(ctx context.Context, f gofish.Food, pkg checksum.Package, opts Options) (string, string, int64, error) {
+	var names []string
+	if name, ok := opts.Checksums[f.Name]; ok {
+		names = append(names, name)
+	}
+	if opts.ObjectChecksums {
+		names = append(names, checksum.ProviderObjectStore)
+	}
+	names = append(names, checksum.ProviderDownload)
+
+	providers := checksumProviders(opts)
+	var bytes int64
+	for _, name := range names {
+		p, ok := providers[name]
+		if !ok {
+			return "", name, bytes, fmt.Errorf("unknown checksum provider: %s", name)
+		}
+		sha, n, err := p.Checksum(ctx, pkg)
+		bytes += n
+		if errors.Is(err, checksum.ErrUnavailable) {
+			continue
+		}
+		return sha, name, bytes, err
+	}
+	return "", "", bytes, checksum.ErrUnavailable
+}
+
+// lockRig takes the lease on the rig for the run.
+func lockRig(ctx context.Context, opts Options) (func(), error) {
+	return rig.Lock(ctx, opts.RigOptions(), actor(), opts.LockTTL)
+}
+
+// openPullRequest opens a pull request on the rig with the changes of the
+// run, described by its report.
+func openPullRequest(ctx context.Context, opts Options) (string, error) {
+	ctx, span := trace.Start(ctx, "open pull request")
+	title, body := pullRequestMessage(opts.Report)
+	url, err := opts.rig.OpenPullRequest(ctx, title, body)
+	span.End(err)
+	return url, err
+}
+
+// filterFeed returns the foods of feed the run is limited to.
+func filterFeed(feed []gofish.Food, opts Options) []gofish.Food {
+	if opts.Only == nil && opts.Upstreams == nil {
+		return feed
+	}
+
+	var filtered []gofish.Food
+	for _, f := range feed {
+		if opts.Only != nil && !opts.Only[f.Name] {
+			continue
+		}
+		if opts.Upstreams != nil {
+			org, repo := source.GithubRepo(f, opts.Release)
+			if len(org) == 0 || !opts.Upstreams[strings.ToLower(org+"/"+repo)] {
+				continue
+			}
+		}
+		filtered = append(filtered, f)
+	}
+	return filtered
+}
+
+// runFood processes f and records its result in the report, replacing any
+// result from an earlier attempt.
+func runFood(ctx context.Context, f gofish.Food, opts Options) (Result, error) {
+	if inActions() {
+		startGroup(f.Name)
+		defer endGroup()
+	}
+
+	emit(opts, Event{Kind: EventStarted, Food: f.Name, Version: f.Version})
+
+	foodCtx := ctx
+	if opts.FoodTimeout > 0 {
+		var cancel context.CancelFunc
+		foodCtx, cancel = context.WithTimeout(ctx, opts.FoodTimeout)
+		defer cancel()
+	}
+
+	foodCtx, span := trace.Start(foodCtx, "food", "food", f.Name, "version", f.Version)
+	start := time.Now()
+	res, err := processFood(foodCtx, f, opts)
+	if err != nil {
+		res.Action = ActionError
+		res.Error = err.Error()
+		res.Transient = transient.Is(err)
+		slog.Error("processing failed", "food", f.Name, "version", f.Version, "transient", res.Transient, "error", err)
+		for _, fn := range opts.Hooks.OnError {
+			fn(ctx, res, err)
+		}
+	}
+	span.SetAttr("action", res.Action)
+	span.End(err)
+	if opts.StatsD != nil {
+		opts.StatsD.observeFood(res, time.Since(start), opts)
+	}
+	if opts.State != nil {
+		prev, err := opts.State.Record(res)
+		if err != nil {
+			return res, err
+		}
+		if len(prev.Upstream) > 0 && len(res.Upstream) > 0 && prev.Upstream != res.Upstream {
+			res.LastUpstream = prev.Upstream
+			slog.Info("upstream changed since last run", "food", f.Name, "version", f.Version, "from", prev.Upstream, "to", res.Upstream)
+		}
+		trackFailures(ctx, res, prev, opts)
+	}
+	if res.Action == ActionUpdated {
+		for _, fn := range opts.Hooks.AfterBump {
+			fn(ctx, res)
+		}
+	}
+	if res.Action == ActionUpdated && opts.Plan != nil {
+		opts.Plan.addResult(res)
+	}
+	if res.Action == ActionUpdated && len(opts.AuditLog) > 0 && !opts.DryRun {
+		err := appendAudit(opts.AuditLog, res)
+		if err != nil {
+			return res, err
+		}
+	}
+	opts.Report.SetResult(res)
+	emit(opts, Event{Kind: resultEvents[res.Action], Food: f.Name, Version: f.Version, Upstream: res.Upstream, Result: &res})
+	return res, nil
+}
+
+func processFood(ctx context.Context, f gofish.Food, opts Options) (Result, error) {
+	res := Result{Food: f.Name, Action: ActionUpToDate, OldVersion: f.Version}
+	skip := func(reason string) (Result, error) {
+		slog.Warn("skipping", "food", f.Name, "version", f.Version, "phase", "resolve", "reason", reason)
+		res.Action = ActionSkipped
+		res.Reason = reason
+		return res, nil
+	}
+	needsHuman := func(reason string) (Result, error) {
+		slog.Warn("needs a human", "food", f.Name, "version", f.Version, "phase", "update", "reason", reason)
+		res.Action = ActionNeedsHuman
+		res.Reason = reason
+		return res, nil
+	}
+
+	if opts.Skip[f.Name] {
+		return skip("skipping")
+	}
+
+	if opts.State != nil {
+		st, _, err := opts.State.Get(f.Name)
+		if err != nil {
+			return res, err
+		}
+		if until := st.FailureTime.Add(st.backoff()); time.Now().Before(until) {
+			return skip(fmt.Sprintf("backing off after %d failures", st.Failures))
+		}
+		if opts.SinceLastRun && st.Version == f.Version && time.Since(st.UpToDate) < opts.Freshness {
+			return skip("up to date since last run")
+		}
+	}
+
+	resolveCtx, span := trace.Start(ctx, "resolve")
+	release, assets, err := source.Latest(resolveCtx, sources(opts), f)
+	span.End(err)
+	var gone *source.GoneError
+	if errors.As(err, &gone) {
+		if err := deprecate(ctx, f, gone.Reason, opts); err != nil {
+			return res, err
+		}
+		return skip(gone.Reason)
+	}
+	if errors.Is(err, source.ErrNoUpstream) {
+		return skip(err.Error())
+	}
+	if err != nil {
+		return res, err
+	}
+
+	if opts.SyncDescription {
+		// The food is bumped from its synced description, so the guard
+		// against unexpected changes expects it.
+		f.Description, err = syncDescription(ctx, f, release.Description, opts)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	version, err := semver.NewVersion(f.Version)
+	if err != nil {
+		return res, fmt.Errorf("semver: %w", err)
+	}
+
+	newVersion, err := semver.NewVersion(release.Tag)
+	if err != nil {
+		return skip("cannot parse semver for: " + release.Tag)
+	}
+	res.Upstream = newVersion.String()
+	emit(opts, Event{Kind: EventResolved, Food: f.Name, Version: f.Version, Upstream: res.Upstream})
+
+	ups, err := packageUpstreams(ctx, f, opts)
+	if errors.As(err, &gone) {
+		return skip(err.Error())
+	}
+	if err != nil {
+		return res, err
+	}
+	var pkgChanges []versionChange
+	for i := range f.Packages {
+		u, ok := ups[i]
+		if !ok {
+			continue
+		}
+		latest, ok := u.newer()
+		if !ok {
+			continue
+		}
+		c, err := addVersionChange(pkgChanges, versionChange{Old: u.Version, New: latest})
+		if err != nil {
+			return res, err
+		}
+		pkgChanges = c
+	}
+
+	downgrade := newVersion.LessThan(version)
+	yanked := false
+	if downgrade {
+		released, err := source.Released(ctx, sources(opts), f, f.Version)
+		if err != nil {
+			return res, err
+		}
+		if !released {
+			opts.Report.Add(f.Name, FindingYankedRelease, fmt.Sprintf("%s no longer exists upstream, the newest release is %s", version, newVersion))
+			if !opts.ReplaceYanked && !opts.AllowDowngrade {
+				return needsHuman(version.String() + " no longer exists upstream")
+			}
+			yanked = true
+		}
+	}
+	if downgrade && !yanked && !opts.AllowDowngrade {
+		opts.Report.Add(f.Name, FindingDowngrade, version.String()+" -> "+newVersion.String())
+		return skip("not downgrading to " + newVersion.String())
+	}
+	bumpFood := downgrade || newVersion.GreaterThan(version)
+	if !bumpFood && len(pkgChanges) == 0 {
+		if opts.VerifyChecksums {
+			return res, checkDrift(ctx, f, assets, ups, &res, opts)
+		}
+		return res, nil
+	}
+
+	for i, pkg := range f.Packages {
+		if _, ok := ups[i]; ok {
+			continue
+		}
+		if v, ok := urlVersion(pkg.URL, f.Version); ok {
+			return skip(fmt.Sprintf("%s/%s package URL has version %s, not %s", pkg.OS, pkg.Arch, v, f.Version))
+		}
+	}
+
+	pinLine := ""
+	if !downgrade && majorLine(newVersion) != majorLine(version) {
+		if opts.PinMajor {
+			pinLine = majorLine(version)
+		} else if opts.HoldMajor {
+			opts.Report.Add(f.Name, FindingHeldMajor, version.String()+" -> "+newVersion.String())
+			return skip("holding major version bump to " + newVersion.String())
+		}
+	}
+	for _, c := range pkgChanges {
+		slog.Info("updating package upstream", "food", f.Name, "version", f.Version, "phase", "update", "from", c.Old, "to", c.New)
+	}
+	switch {
+	case yanked:
+		slog.Warn("replacing deleted release", "food", f.Name, "version", f.Version, "phase", "update", "new_version", newVersion.String())
+	case downgrade:
+		slog.Warn("downgrading", "food", f.Name, "version", f.Version, "phase", "update", "new_version", newVersion.String())
+		opts.Report.Add(f.Name, FindingDowngrade, version.String()+" -> "+newVersion.String()+" (allowed)")
+	case bumpFood:
+		slog.Info("updating", "food", f.Name, "version", f.Version, "phase", "update", "new_version", newVersion.String())
+	}
+
+	food, err := rig.CopyFood(f)
+	if err != nil {
+		return res, fmt.Errorf("copying food: %w", err)
+	}
+	if bumpFood {
+		food.Version = newVersion.String()
+		res.ReleaseURL = release.URL
+	}
+	res.NewVersion = food.Version
+	changes := append([]versionChange{{Old: f.Version, New: food.Version}}, pkgChanges...)
+
+	var dropped []string
+	for i, pkg := range f.Packages {
+		newURL := replaceVersions(pkg.URL, changes)
+		if !bumpFood && newURL == pkg.URL {
+			continue
+		}
+		pkgAssets := assets
+		if u, ok := ups[i]; ok {
+			pkgAssets = u.Assets
+		}
+		if droppedPlatform(newURL, pkgAssets) {
+			dropped = append(dropped, pkg.OS+"/"+pkg.Arch)
+		}
+	}
+	if len(dropped) > 0 {
+		opts.Report.Add(f.Name, FindingPlatformRegression, fmt.Sprintf("%s has no assets for %s", food.Version, strings.Join(dropped, ", ")))
+		return needsHuman("release drops " + strings.Join(dropped, ", "))
+	}
+
+	// Packages whose checksums were not computed by downloading them are
+	// not downloaded to lint them either.
+	published := map[string]bool{}
+	var formats []formatChange
+	switched := map[int]bool{}
+	for i, pkg := range food.Packages {
+		newURL := replaceVersions(pkg.URL, changes)
+		// Packages left as they are by a bump of other packages only keep
+		// their checksum; lint still verifies it.
+		if !bumpFood && newURL == pkg.URL {
+			continue
+		}
+		pkgAssets, tag := assets, release.Tag
+		if u, ok := ups[i]; ok {
+			pkgAssets, tag = u.Assets, u.Latest.Tag
+		}
+		if c, ok := switchedFormat(newURL, pkgAssets); ok {
+			slog.Info("package format changed", "food", f.Name, "version", f.Version, "phase", "update", "url", newURL, "from", c.Old, "to", c.New)
+			formats, err = addFormatChange(formats, c)
+			if err != nil {
+				return res, err
+			}
+			switched[i] = true
+			newURL = replaceExtension(newURL, []formatChange{c})
+		}
+		if err := checkAsset(ctx, newURL, tag, opts); err != nil {
+			return res, err
+		}
+		emit(opts, Event{Kind: EventDownloading, Food: f.Name, Version: f.Version, Upstream: res.Upstream, URL: newURL})
+		var paths []string
+		for _, r := range pkg.Resources {
+			paths = append(paths, replaceVersions(r.Path, changes))
+		}
+		sha, provider, n, err := packageChecksum(ctx, f, checksum.Package{URL: newURL, Assets: pkgAssets, Paths: paths}, opts)
+		res.Bytes += n
+		var missingErr *checksum.MissingPathsError
+		if errors.As(err, &missingErr) {
+			opts.Report.Add(f.Name, FindingMissingPath, fmt.Sprintf("%s/%s: %v", pkg.OS, pkg.Arch, err))
+			return skip(fmt.Sprintf("%s/%s package does not contain %s", pkg.OS, pkg.Arch, strings.Join(missingErr.Paths, ", ")))
+		}
+		if err != nil {
+			return res, err
+		}
+		if provider != checksum.ProviderDownload {
+			slog.Debug("using published checksum", "food", f.Name, "version", f.Version, "phase", "checksum", "url", newURL, "provider", provider)
+			published[newURL] = true
+		}
+
+		var size, oldSize int64
+		if provider == checksum.ProviderDownload {
+			size, oldSize = n, contentLength(ctx, pkg.URL, opts)
+			if oldSize > 0 && size < oldSize/sizeDropRatio {
+				slog.Warn("package much smaller than previous version", "food", f.Name, "version", f.Version, "phase", "checksum", "url", newURL, "size", size, "old_size", oldSize)
+				opts.Report.Add(f.Name, FindingSizeDrop, fmt.Sprintf("%s/%s: %s is %d bytes, %s was %d bytes", pkg.OS, pkg.Arch, newURL, size, pkg.URL, oldSize))
+			}
+		}
+
+		food.Packages[i].URL = newURL
+		food.Packages[i].SHA256 = sha
+		res.Packages = append(res.Packages, PackageChange{
+			OS:        pkg.OS,
+			Arch:      pkg.Arch,
+			OldURL:    f.Packages[i].URL,
+			URL:       newURL,
+			OldSHA256: f.Packages[i].SHA256,
+			SHA256:    sha,
+			Size:      size,
+			OldSize:   max(oldSize, 0),
+		})
+	}
+
+	// Update lua
+	foodFilePath := opts.rig.FoodFile(f.Name)
+	src, mode, err := readFoodFile(foodFilePath, opts)
+	if err != nil {
+		return res, err
+	}
+
+	updatedFood, err := rewriteFood(src, f, food, changes)
+	if err != nil {
+		return res, fmt.Errorf("rewriting food: %w", err)
+	}
+	updatedFood = replaceFormats(updatedFood, formats)
+
+	// Lint the food as it will be written, so a failure leaves the file untouched
+	expected := expectedFood(f, food, changes)
+	for i := range switched {
+		p := expected.Packages[i]
+		p.URL = replaceExtension(p.URL, formats)
+		for j, m := range p.Mirrors {
+			p.Mirrors[j] = replaceExtension(m, formats)
+		}
+	}
+	food, err = rig.ParseFood(updatedFood)
+	if err != nil {
+		return res, fmt.Errorf("parsing updated food: %w", err)
+	}
+	if diff := foodDiff(expected, food); len(diff) > 0 {
+		return res, fmt.Errorf("rewriting food: replacing the version and checksums changed %s unexpectedly", strings.Join(diff, ", "))
+	}
+	if len(opts.GofishVersion) > 0 {
+		fields, err := incompatibleFields(updatedFood, opts.GofishVersion)
+		if err != nil {
+			return res, err
+		}
+		if len(fields) > 0 {
+			msg := fmt.Sprintf("fields not read by gofish %s: %s", opts.GofishVersion, strings.Join(fields, ", "))
+			slog.Warn("food incompatible with gofish", "food", f.Name, "version", f.Version, "phase", "lint", "gofish", opts.GofishVersion, "fields", fields)
+			opts.Report.Add(f.Name, FindingGofishCompat, msg)
+			return skip("incompatible with gofish " + opts.GofishVersion)
+		}
+	}
+	errs := checksum.Lint(food, published, opts.Mirrors)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			slog.Warn("lint error", "food", f.Name, "version", f.Version, "phase", "lint", "error", err)
+			res.LintErrors = append(res.LintErrors, err.Error())
+			if strings.Contains(err.Error(), "shasum verify check failed") {
+				opts.Report.Add(f.Name, FindingChecksumMismatch, err.Error())
+			} else {
+				opts.Report.Add(f.Name, FindingLint, err.Error())
+			}
+		}
+		return skip("lint failed")
+	}
+	if opts.SandboxInstall || opts.SmokeTest {
+		err := sandboxInstall(ctx, food, updatedFood, &res, opts)
+		var checkErr checkError
+		if errors.As(err, &checkErr) {
+			slog.Warn("installed package check failed", "food", f.Name, "version", f.Version, "phase", "install", "check", checkErr.Finding, "error", err)
+			opts.Report.Add(f.Name, checkErr.Finding, err.Error())
+			return skip(checkErr.Finding + " failed")
+		}
+		if transient.Is(err) {
+			return res, err
+		}
+		if err != nil {
+			slog.Warn("sandbox install failed", "food", f.Name, "version", f.Version, "phase", "install", "error", err)
+			opts.Report.Add(f.Name, FindingInstall, err.Error())
+			return skip("sandbox install failed")
+		}
+	}
+
+	adjusted := food
+	if err := opts.Hooks.beforeBump(ctx, f, &adjusted); err != nil {
+		return skip("vetoed: " + err.Error())
+	}
+	reason, err := opts.rig.PreBump(f, &adjusted)
+	if err != nil {
+		return res, fmt.Errorf("pre_bump hook: %w", err)
+	}
+	if len(reason) > 0 {
+		return skip("vetoed: " + reason)
+	}
+	if adjusted.Description != food.Description {
+		updatedFood = setField(updatedFood, descriptionRegex, adjusted.Description)
+	}
+	if adjusted.Homepage != food.Homepage {
+		updatedFood = setField(updatedFood, homepageRegex, adjusted.Homepage)
+	}
+
+	if len(pinLine) > 0 {
+		err := pinFood(ctx, f, pinLine, opts)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	err = writeFoodFile(ctx, foodFilePath, src, updatedFood, mode, opts)
+	if err != nil {
+		return res, err
+	}
+
+	res.Action = ActionUpdated
+	return res, nil
+}
+
+// checkDrift recomputes the checksums of the packages of f, which is up to
+// date, and reports those that no longer match: the upstream replaced the
+// files of the release without a new version. Packages in ups use the
+// assets of their own upstream.
+func checkDrift(ctx context.Context, f gofish.Food, assets []source.Asset, ups map[int]packageUpstream, res *Result, opts Options) error {
+	for i, pkg := range f.Packages {
+		pkgAssets := assets
+		if u, ok := ups[i]; ok {
+			pkgAssets = u.Assets
+		}
+		sha, _, n, err := packageChecksum(ctx, f, checksum.Package{URL: pkg.URL, Assets: pkgAssets}, opts)
+		res.Bytes += n
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sha, pkg.SHA256) {
+			slog.Warn("checksum drift", "food", f.Name, "version", f.Version, "phase", "checksum", "url", pkg.URL, "sha256", pkg.SHA256, "now", sha)
+			opts.Report.Add(f.Name, FindingChecksumDrift, fmt.Sprintf("%s/%s: %s changed from %s to %s without a new version", pkg.OS, pkg.Arch, pkg.URL, pkg.SHA256, sha))
+		}
+	}
+	return nil
+}
+
+// deprecate records f as a deprecation candidate and, if enabled, opens an
+// issue on the rig so the food can be removed or repointed.
+func deprecate(ctx context.Context, f gofish.Food, reason string, opts Options) error {
+	opts.Report.Add(f.Name, FindingDeprecationCandidate, reason)
+
+	if !opts.OpenIssues {
+		return nil
+	}
+
+	org, repo, err := rig.Repo(opts.RigOptions())
+	if err != nil {
+		return fmt.Errorf("opening issue: %w", err)
+	}
+
+	title := "Deprecation candidate: " + f.Name
+	issue, err := findIssue(ctx, org, repo, title, opts)
+	if err != nil || issue != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("`%s` may need to be removed or repointed: %s.", f.Name, reason)
+	_, _, err = opts.GithubClient.Issues.Create(ctx, org, repo, &github.IssueRequest{Title: &title, Body: &body})
+	if err != nil {
+		return fmt.Errorf("opening issue: %w", err)
+	}
+	return nil
+}
+
+// majorLine returns the release line of v used for pinned foods: the major
+// version, or major.minor while the major version is 0.
+func majorLine(v *semver.Version) string {
+	if v.Major() == 0 {
+		return fmt.Sprintf("0.%d", v.Minor())
+	}
+	return strconv.FormatInt(v.Major(), 10)
+}
+
+var nameRegex = regexp.MustCompile(`(?m)^(\s*name\s*=\s*)(.+?)(,?)[ \t\r]*$`)
+
+// pinFood writes a copy of f named f@line, preserving the current release
+// line before f is bumped past it.
+func pinFood(ctx context.Context, f gofish.Food, line string, opts Options) error {
+	pinned := f.Name + "@" + line
+	pinnedFilePath := filepath.Join(filepath.Dir(opts.rig.FoodFile(f.Name)), pinned+".lua")
+
+	fs := opts.rig.Fs()
+	if ok, err := afero.Exists(fs, pinnedFilePath); err != nil || ok {
+		return err
+	}
+	slog.Info("pinning", "food", f.Name, "version", f.Version, "phase", "pin", "pinned", pinned)
+
+	foodFilePath := opts.rig.FoodFile(f.Name)
+	src, mode, err := readFoodFile(foodFilePath, opts)
+	if err != nil {
+		return err
+	}
+
+	loc := nameRegex.FindStringSubmatchIndex(src)
+	if loc == nil {
+		return fmt.Errorf("pinning: cannot find name in %s", foodFilePath)
+	}
+	src = src[:loc[4]] + luaQuote(pinned) + src[loc[5]:]
+
+	return writeFoodFile(ctx, pinnedFilePath, "", src, mode, opts)
+}
+
+var descriptionRegex = regexp.MustCompile(`(?m)^(\s*description\s*=\s*)(.+?)(,?)[ \t\r]*$`)
+
+// syncDescription replaces the description of f with description, the
+// description of its upstream, and returns the description of f afterwards.
+// It is left unchanged if description is empty or the food file sets no
+// description to replace.
+func syncDescription(ctx context.Context, f gofish.Food, description string, opts Options) (string, error) {
+	description = strings.TrimSpace(description)
+	if len(description) == 0 || description == f.Description {
+		return f.Description, nil
+	}
+
+	synced := f.Description
+	foodFilePath := opts.rig.FoodFile(f.Name)
+	err := rewriteFoodFile(ctx, foodFilePath, func(src string) string {
+		updated := setField(src, descriptionRegex, description)
+		if updated != src {
+			slog.Info("updating description", "food", f.Name, "version", f.Version, "phase", "describe")
+			synced = description
+		}
+		return updated
+	}, opts)
+	return synced, err
+}
+
+var homepageRegex = regexp.MustCompile(`(?m)^(\s*homepage\s*=\s*)(.+?)(,?)[ \t\r]*$`)
+
+// setField replaces the value of the field of the food in src matched by re
+// with the string value. src is returned unchanged if the field is not found.
+func setField(src string, re *regexp.Regexp, value string) string {
+	loc := re.FindStringSubmatchIndex(src)
+	if loc == nil {
+		return src
+	}
+	return src[:loc[4]] + luaQuote(value) + src[loc[5]:]
+}
+
+// luaQuote returns s as a double-quoted lua string literal.
+func luaQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func rewriteFoodFile(ctx context.Context, foodFilePath string, update func(string) string, opts Options) error {
+	src, mode, err := readFoodFile(foodFilePath, opts)
+	if err != nil {
+		return err
+	}
+
+	return writeFoodFile(ctx, foodFilePath, src, update(src), mode, opts)
+}
+
+// readFoodFile reads the food file at foodFilePath. Plans leave the rig
+// unchanged, so files already changed by the plan of the run are read as
+// planned.
+func readFoodFile(foodFilePath string, opts Options) (string, os.FileMode, error) {
+	if opts.Plan != nil {
+		if src, mode, ok := opts.Plan.planned(opts.rig.Rel(foodFilePath)); ok {
+			return src, mode, nil
+		}
+	}
+	return rig.ReadFoodFile(opts.rig.Fs(), foodFilePath)
+}
+
+// writeFoodFile replaces old, the contents of foodFilePath, with src through
+// the updater of the run, keeping the line endings of old. An empty old
+// creates the file.
+func writeFoodFile(ctx context.Context, foodFilePath, old, src string, mode os.FileMode, opts Options) error {
+	ctx, span := trace.Start(ctx, "write food", "path", opts.rig.Rel(foodFilePath))
+	src = matchLineEndings(old, src)
+	err := updater(opts).Update(ctx, FileChange{Path: opts.rig.Rel(foodFilePath), Old: old, New: src, Mode: mode})
+	span.End(err)
+	return err
+}
+
+// pullRequestMessage returns the title and body describing the updates in r.
+func pullRequestMessage(r *Report) (string, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var updated []Result
+	for _, res := range r.Results {
+		if res.Action == ActionUpdated {
+			updated = append(updated, res)
+		}
+	}
+
+	var title string
+	switch len(updated) {
+	case 0:
+		title = "Update foods"
+	case 1:
+		title = fmt.Sprintf("Bump %s to %s", updated[0].Food, updated[0].NewVersion)
+	default:
+		title = fmt.Sprintf("Bump %d foods", len(updated))
+	}
+
+	var body strings.Builder
+	for _, res := range updated {
+		fmt.Fprintf(&body, "- %s: %s → %s", res.Food, res.OldVersion, res.NewVersion)
+		if len(res.ReleaseURL) > 0 {
+			fmt.Fprintf(&body, " ([release](%s))", res.ReleaseURL)
+		}
+		body.WriteString("\n")
+	}
+	if len(updated) == 0 {
+		body.WriteString("Sync food metadata with upstream.\n")
+	}
+	return title, body.String()
+}
@@ -0,0 +1,142 @@
+package bump
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+)
+
+// testSource releases every food at version with description.
+type testSource struct {
+	version     string
+	description string
+}
+
+func (s testSource) LatestVersion(ctx context.Context, f gofish.Food) (source.Version, []source.Asset, error) {
+	return source.Version{Tag: s.version, Description: s.description}, nil, nil
+}
+
+// testPackage returns a gzipped tarball holding bin/x.
+func testPackage(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	body := []byte("#!/bin/sh\necho x\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "bin/x", Mode: 0755, Size: int64(len(body))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(body)
+	tw.Close()
+	gz.Close()
+	return b.Bytes()
+}
+
+// testRig writes food, with SRV replaced by the URL of a server of
+// testPackage, as the food x of a rig in a temporary directory, and returns
+// the path of the rig.
+func testRig(t *testing.T, food string) string {
+	t.Helper()
+	// gofish caches the packages it downloads in the home directory.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, ".gofish"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pkg := testPackage(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pkg)
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "Food"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := strings.ReplaceAll(food, "SRV", srv.URL)
+	if err := os.WriteFile(filepath.Join(dir, "Food", "x.lua"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const testFood = `local version = "1.0.0"
+food = {
+    name = "x",
+    description = "An old description",
+    homepage = "https://example.com/x",
+    version = version,
+    packages = {
+        {
+            os = "linux",
+            arch = "amd64",
+            url = "SRV/x-" .. version .. ".tgz",
+            sha256 = "0000000000000000000000000000000000000000000000000000000000000000",
+            resources = { { path = "bin/x", installpath = "bin/x", executable = true } }
+        }
+    }
+}
+`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		food            string
+		syncDescription bool
+		description     string
+		contains        []string
+	}{
+		{
+			name:     "bump",
+			food:     testFood,
+			contains: []string{`local version = "2.0.0"`, `description = "An old description"`},
+		},
+		{
+			name:            "bump and sync description",
+			food:            testFood,
+			syncDescription: true,
+			description:     "A new description",
+			contains:        []string{`local version = "2.0.0"`, `description = "A new description"`},
+		},
+		{
+			name:     "empty resources and mirrors",
+			food:     strings.Replace(testFood, `resources = { { path = "bin/x", installpath = "bin/x", executable = true } }`, "mirrors = {},\n            resources = {}", 1),
+			contains: []string{`local version = "2.0.0"`, "resources = {}", "mirrors = {}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testRig(t, tt.food)
+			b := New(WithRig(dir), WithSource(testSource{version: "2.0.0", description: tt.description}), func(o *Options) {
+				o.SyncDescription = tt.syncDescription
+			})
+			res, err := b.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Report.Results) != 1 || res.Report.Results[0].Action != ActionUpdated {
+				t.Fatalf("got results %+v, want x updated", res.Report.Results)
+			}
+
+			got, err := os.ReadFile(filepath.Join(dir, "Food", "x.lua"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(string(got), s) {
+					t.Errorf("food does not contain %s:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
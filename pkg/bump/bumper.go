@@ -0,0 +1,356 @@
+package bump
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/arbourd/gfb/internal/trace"
+	"github.com/arbourd/gfb/pkg/checksum"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+	"github.com/spf13/afero"
+)
+
+// Option configures a Bumper.
+type Option func(*Options)
+
+// WithOptions replaces the options of the bumper with opts. Options after it
+// change opts further.
+func WithOptions(opts Options) Option {
+	return func(o *Options) { *o = opts }
+}
+
+// WithRig sets the URL of the rig, or the path of a local checkout of it.
+func WithRig(rig string) Option {
+	return func(o *Options) { o.Rig = rig }
+}
+
+// WithGithubToken sets the token authenticating to GitHub.
+func WithGithubToken(token string) Option {
+	return func(o *Options) { o.GithubAuthToken = token }
+}
+
+// WithHTTPClient sets the client downloading packages and talking to GitHub
+// and the forge hosting the rig.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) { o.HTTPClient = client }
+}
+
+// WithFs sets the filesystem holding local checkouts of the rig.
+func WithFs(fs afero.Fs) Option {
+	return func(o *Options) { o.Fs = fs }
+}
+
+// WithDryRun prints the changes to the foods instead of writing them.
+func WithDryRun(dryRun bool) Option {
+	return func(o *Options) { o.DryRun = dryRun }
+}
+
+// WithOnly limits the run to the named foods.
+func WithOnly(foods ...string) Option {
+	return func(o *Options) {
+		o.Only = map[string]bool{}
+		for _, f := range foods {
+			o.Only[f] = true
+		}
+	}
+}
+
+// WithSkip skips the named foods.
+func WithSkip(foods ...string) Option {
+	return func(o *Options) {
+		if o.Skip == nil {
+			o.Skip = map[string]bool{}
+		}
+		for _, f := range foods {
+			o.Skip[f] = true
+		}
+	}
+}
+
+// WithSource adds a source resolving releases of foods, tried before the
+// sources added after it and GitHub.
+func WithSource(s source.Source) Option {
+	return func(o *Options) { o.Sources = append(o.Sources, s) }
+}
+
+// WithChecksumProvider adds a provider of checksums named name, which foods
+// can select in Options.Checksums.
+func WithChecksumProvider(name string, p checksum.Provider) Option {
+	return func(o *Options) {
+		if o.ChecksumProviders == nil {
+			o.ChecksumProviders = map[string]checksum.Provider{}
+		}
+		o.ChecksumProviders[name] = p
+	}
+}
+
+// WithUpdater adds a target the changes of the run are applied to, after
+// they are written to the rig.
+func WithUpdater(u Updater) Option {
+	return func(o *Options) { o.Updaters = append(o.Updaters, u) }
+}
+
+// WithEvents calls fn with the events of each run as they happen, such as to
+// render its progress live. fn is called from the goroutine of the run.
+func WithEvents(fn func(Event)) Option {
+	return func(o *Options) { o.Events = fn }
+}
+
+// Bumper bumps the foods of a rig. A Bumper runs one thing at a time.
+type Bumper struct {
+	opts    Options
+	feed    []gofish.Food
+	cleanup func()
+}
+
+// New returns a Bumper configured by opts.
+func New(opts ...Option) *Bumper {
+	b := &Bumper{}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	return b
+}
+
+// RunResult is the outcome of a run.
+type RunResult struct {
+	Report *Report
+	// Status is the exit status of the run.
+	Status int
+	// PullRequest is the URL of the pull request opened by the run, if any.
+	PullRequest string
+}
+
+// Load loads the rig and parses its foods, starting a new report. The rig is
+// kept until Close is called.
+func (b *Bumper) Load(ctx context.Context) ([]gofish.Food, error) {
+	b.Close()
+	feed, cleanup, err := LoadRig(ctx, &b.opts)
+	if err != nil {
+		return nil, err
+	}
+	b.feed, b.cleanup = feed, cleanup
+	return feed, nil
+}
+
+// Close removes the clone of the rig, if any.
+func (b *Bumper) Close() {
+	if b.cleanup != nil {
+		b.cleanup()
+	}
+	b.feed, b.cleanup = nil, nil
+}
+
+// Report returns the report of the last run, or of the foods processed since
+// the rig was loaded.
+func (b *Bumper) Report() *Report {
+	return b.opts.Report
+}
+
+// ProcessFood bumps f, loading the rig first if needed, and records its
+// result in the report. Failures to bump f are returned in the result; the
+// error is only set if the result could not be recorded.
+func (b *Bumper) ProcessFood(ctx context.Context, f gofish.Food) (Result, error) {
+	if b.cleanup == nil {
+		if _, err := b.Load(ctx); err != nil {
+			return Result{}, err
+		}
+	}
+	return runFood(ctx, f, b.opts)
+}
+
+// Run bumps the foods of the rig to their latest upstream releases.
+func (b *Bumper) Run(ctx context.Context) (RunResult, error) {
+	var tracer *trace.Tracer
+	if len(b.opts.OTLPEndpoint) > 0 {
+		tracer = &trace.Tracer{Endpoint: b.opts.OTLPEndpoint, Headers: b.opts.OTLPHeaders, Client: httpClient(b.opts)}
+		ctx = trace.WithTracer(ctx, tracer)
+	}
+	runCtx, span := trace.Start(ctx, "run", "rig", b.opts.Rig)
+
+	start := time.Now()
+	res, err := b.run(runCtx)
+	span.End(err)
+	if b.opts.Metrics != nil {
+		b.observeRun(ctx, res, err, time.Since(start))
+	}
+	if tracer != nil {
+		if err := tracer.Flush(ctx); err != nil {
+			slog.Warn("exporting traces failed", "phase", "report", "error", err)
+		}
+	}
+	return res, err
+}
+
+// observeRun records the run in the metrics, and pushes them to the
+// Pushgateway if one is set. Failing to push is logged, not returned.
+func (b *Bumper) observeRun(ctx context.Context, res RunResult, err error, elapsed time.Duration) {
+	outcome := OutcomeSuccess
+	switch {
+	case err != nil:
+		outcome = OutcomeError
+	case res.Status != 0:
+		outcome = OutcomeFailure
+	}
+	b.opts.Metrics.observeRun(res.Report, outcome, elapsed)
+
+	if len(b.opts.Pushgateway) > 0 {
+		if err := b.opts.Metrics.Push(ctx, httpClient(b.opts), b.opts.Pushgateway); err != nil {
+			slog.Warn("pushing metrics failed", "phase", "report", "error", err)
+		}
+	}
+}
+
+// finishTimeout bounds reporting on a run that reached its deadline.
+const finishTimeout = 30 * time.Second
+
+func (b *Bumper) run(parent context.Context) (RunResult, error) {
+	opts := &b.opts
+	ctx := parent
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, opts.Timeout)
+		defer cancel()
+	}
+
+	if opts.OpenPR && !opts.DryRun {
+		SetupGithub(ctx, opts)
+		unlock, err := lockRig(ctx, *opts)
+		if err != nil {
+			return RunResult{Status: ExitFailure}, err
+		}
+		defer unlock()
+	}
+
+	feed, err := b.Load(ctx)
+	if err != nil {
+		return RunResult{Status: ExitFailure}, err
+	}
+	defer b.Close()
+	res := RunResult{Report: opts.Report, Status: ExitFailure}
+
+	analyzeRig(feed, *opts)
+	lintURLVersions(feed, *opts)
+	feed = filterFeed(feed, *opts)
+	if opts.CheckURLs {
+		checkURLs(ctx, feed, *opts)
+	}
+
+	start := time.Now()
+	p := newProgress(len(feed))
+	var retry []gofish.Food
+	for i, f := range feed {
+		if ctx.Err() != nil {
+			slog.Warn("run deadline exceeded", "not_attempted", len(feed)-i)
+			for _, f := range feed[i:] {
+				opts.Report.SetResult(Result{Food: f.Name, Action: ActionNotAttempted, OldVersion: f.Version})
+			}
+			break
+		}
+
+		fres, err := b.ProcessFood(ctx, f)
+		if err != nil {
+			return res, err
+		}
+		p.step(fres)
+		if fres.Action == ActionError && fres.Transient {
+			retry = append(retry, f)
+		}
+		if fres.Action == ActionError && opts.FailFast {
+			slog.Warn("stopping run at first failure", "food", f.Name, "version", f.Version, "not_processed", len(feed)-i-1)
+			for _, f := range feed[i+1:] {
+				opts.Report.SetResult(Result{Food: f.Name, Action: ActionSkipped, Reason: "not processed: fail-fast", OldVersion: f.Version})
+			}
+			retry = nil
+			break
+		}
+	}
+
+	if opts.Retry && len(retry) > 0 && ctx.Err() == nil {
+		slog.Info("retrying transient failures", "foods", len(retry), "delay", opts.RetryDelay.String())
+		select {
+		case <-ctx.Done():
+			retry = nil
+		case <-time.After(opts.RetryDelay):
+		}
+		for _, f := range retry {
+			if ctx.Err() != nil {
+				break
+			}
+			_, err := b.ProcessFood(ctx, f)
+			if err != nil {
+				return res, err
+			}
+		}
+	}
+
+	if err := parent.Err(); err != nil {
+		return res, err
+	}
+	// The run deadline bounds processing foods, not reporting on them: the
+	// pull request, check run and notifications get their own time.
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(parent), finishTimeout)
+		defer cancel()
+	}
+	if err := flushUpdaters(ctx, *opts); err != nil {
+		return res, err
+	}
+
+	if inActions() {
+		for _, r := range opts.Report.Results {
+			annotate(r, *opts)
+		}
+	}
+	opts.Report.Print()
+	opts.Report.PrintSummary(time.Since(start))
+
+	if len(opts.ReportJSON) > 0 {
+		err := opts.Report.WriteJSON(opts.ReportJSON)
+		if err != nil {
+			return res, err
+		}
+	}
+	if len(opts.ReportSARIF) > 0 {
+		err := writeSARIF(opts.ReportSARIF, opts.Report, *opts)
+		if err != nil {
+			return res, err
+		}
+	}
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); len(path) > 0 {
+		err := writeStepSummary(path, opts.Report)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	publishCheckRun(ctx, opts.Report, *opts)
+	sendAlerts(ctx, opts.Report, *opts)
+
+	if opts.OpenPR && !opts.DryRun {
+		res.PullRequest, err = openPullRequest(ctx, *opts)
+		if err != nil {
+			return res, err
+		}
+	}
+	if path := os.Getenv("GITHUB_OUTPUT"); len(path) > 0 {
+		err := writeOutputs(path, opts.Report, res.PullRequest, *opts)
+		if err != nil {
+			return res, err
+		}
+	}
+	// Plans are announced when they are applied.
+	if opts.Plan == nil && !opts.DryRun {
+		notify(ctx, opts.Report, res.PullRequest, *opts)
+		postReport(ctx, opts.Report, res.PullRequest, *opts)
+		emailReport(opts.Report, res.PullRequest, *opts)
+	}
+
+	res.Status = opts.Report.ExitStatus(opts.MaxErrors)
+	return res, nil
+}
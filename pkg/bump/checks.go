@@ -0,0 +1,185 @@
+package bump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arbourd/gfb/pkg/rig"
+	"github.com/google/go-github/v39/github"
+)
+
+const (
+	// checkRunName is the name of the check runs gfb publishes.
+	checkRunName = "gfb"
+	// maxAnnotations is the number of annotations GitHub accepts in each
+	// request creating or updating a check run.
+	maxAnnotations = 50
+	// maxCheckSummary is the longest summary GitHub accepts for a check run.
+	maxCheckSummary = 65535
+)
+
+// annotationLevels maps the levels of SARIF results to the levels of check
+// run annotations.
+var annotationLevels = map[string]string{
+	"error":   "failure",
+	"warning": "warning",
+}
+
+// publishCheckRun publishes the report of the run as a completed check run
+// on the head commit of the rig, annotating the files of the foods that were
+// skipped, failed or have findings. Failing to publish is logged, not
+// returned, so it never fails the run.
+func publishCheckRun(ctx context.Context, r *Report, opts Options) {
+	if !opts.CheckRun {
+		return
+	}
+	if err := createCheckRun(ctx, r, opts); err != nil {
+		slog.Warn("publishing check run failed", "phase", "report", "error", err)
+	}
+}
+
+func createCheckRun(ctx context.Context, r *Report, opts Options) error {
+	org, repo, err := rig.Repo(opts.RigOptions())
+	if err != nil {
+		return err
+	}
+	sha, err := checkRunSHA(opts)
+	if err != nil {
+		return fmt.Errorf("finding head commit: %w", err)
+	}
+
+	summary := markdownSummary(r)
+	if len(summary) > maxCheckSummary {
+		cut := strings.LastIndex(summary[:maxCheckSummary-len("\n…")], "\n")
+		summary = summary[:cut] + "\n…"
+	}
+	conclusion, title := checkConclusion(r)
+	annotations := checkAnnotations(r, opts)
+
+	first := annotations
+	if len(first) > maxAnnotations {
+		first = first[:maxAnnotations]
+	}
+	output := &github.CheckRunOutput{Title: &title, Summary: &summary, Annotations: first}
+	run, _, err := opts.GithubClient.Checks.CreateCheckRun(ctx, org, repo, github.CreateCheckRunOptions{
+		Name:        checkRunName,
+		HeadSHA:     sha,
+		Status:      github.String("completed"),
+		Conclusion:  &conclusion,
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output:      output,
+	})
+	if err != nil {
+		return fmt.Errorf("creating check run: %w", err)
+	}
+
+	// Annotations past the first batch are added by updating the run.
+	for i := maxAnnotations; i < len(annotations); i += maxAnnotations {
+		batch := annotations[i:min(i+maxAnnotations, len(annotations))]
+		output := &github.CheckRunOutput{Title: &title, Summary: &summary, Annotations: batch}
+		_, _, err := opts.GithubClient.Checks.UpdateCheckRun(ctx, org, repo, run.GetID(), github.UpdateCheckRunOptions{Name: checkRunName, Output: output})
+		if err != nil {
+			return fmt.Errorf("annotating check run: %w", err)
+		}
+	}
+	slog.Info("published check run", "phase", "report", "url", run.GetHTMLURL(), "conclusion", conclusion, "annotations", len(annotations))
+	return nil
+}
+
+// checkRunSHA returns the commit the check run is published on: the head of
+// the pull request or the commit that triggered the workflow in GitHub
+// Actions, or the commit of the rig that was loaded.
+func checkRunSHA(opts Options) (string, error) {
+	if inActions() {
+		if path := os.Getenv("GITHUB_EVENT_PATH"); len(path) > 0 {
+			var event struct {
+				PullRequest struct {
+					Head struct {
+						SHA string `json:"sha"`
+					} `json:"head"`
+				} `json:"pull_request"`
+			}
+			b, err := os.ReadFile(path)
+			if err == nil && json.Unmarshal(b, &event) == nil && len(event.PullRequest.Head.SHA) > 0 {
+				return event.PullRequest.Head.SHA, nil
+			}
+		}
+		if sha := os.Getenv("GITHUB_SHA"); len(sha) > 0 {
+			return sha, nil
+		}
+	}
+	return opts.rig.Head()
+}
+
+// checkConclusion returns the conclusion and title of the check run of r: a
+// failure if any food failed or has an error finding, neutral if any was
+// skipped, needs a human or has other findings, and a success otherwise.
+func checkConclusion(r *Report) (string, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, res := range r.Results {
+		counts[res.Action]++
+	}
+	errorFindings := 0
+	for _, f := range r.Findings {
+		if sarifLevels[f.Kind] == "error" {
+			errorFindings++
+		}
+	}
+	title := fmt.Sprintf("%d updated, %d failed, %d need a human, %d findings", counts[ActionUpdated], counts[ActionError], counts[ActionNeedsHuman], len(r.Findings))
+
+	switch {
+	case counts[ActionError] > 0 || errorFindings > 0:
+		return "failure", title
+	case counts[ActionSkipped]+counts[ActionNeedsHuman]+counts[ActionNotAttempted] > 0 || len(r.Findings) > 0:
+		return "neutral", title
+	}
+	return "success", title
+}
+
+// checkAnnotations returns the annotations of the check run of r on the files
+// of the foods: one for each food that was skipped, failed or needs a human,
+// and one for each finding.
+func checkAnnotations(r *Report, opts Options) []*github.CheckRunAnnotation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var annotations []*github.CheckRunAnnotation
+	add := func(food, level, title, message string) {
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            github.String(foodFileURI(food, opts)),
+			StartLine:       github.Int(1),
+			EndLine:         github.Int(1),
+			AnnotationLevel: github.String(level),
+			Title:           github.String(title),
+			Message:         github.String(message),
+		})
+	}
+	for _, res := range r.Results {
+		switch res.Action {
+		case ActionError:
+			add(res.Food, "failure", res.Food+": "+res.Action, res.Detail())
+		case ActionSkipped, ActionNeedsHuman:
+			add(res.Food, "warning", res.Food+": "+res.Action, res.Detail())
+		}
+	}
+	for _, f := range r.Findings {
+		level, ok := annotationLevels[sarifLevels[f.Kind]]
+		if !ok {
+			level = "notice"
+		}
+		title, ok := findingSections[f.Kind]
+		if !ok {
+			title = f.Kind
+		}
+		add(f.Food, level, title, f.Message)
+	}
+	return annotations
+}
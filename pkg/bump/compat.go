@@ -0,0 +1,101 @@
+package bump
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/arbourd/gfb/pkg/rig"
+)
+
+// gofishFields maps gofish releases to the fields of foods they read, as
+// dotted paths in lower case. A release reads the fields of the newest
+// release listed at or before it. Each set is taken from the structs of that
+// release, so only releases gfb has been built with are listed.
+var gofishFields = map[string][]string{
+	"0.14.0": {
+		"caveats",
+		"description",
+		"homepage",
+		"license",
+		"name",
+		"packages",
+		"packages.arch",
+		"packages.mirrors",
+		"packages.os",
+		"packages.resources",
+		"packages.resources.executable",
+		"packages.resources.installpath",
+		"packages.resources.path",
+		"packages.sha256",
+		"packages.url",
+		"postinstallscript",
+		"preinstallscript",
+		"rig",
+		"version",
+	},
+}
+
+// CheckGofishVersion returns an error if the fields read by gofish version
+// are not known.
+func CheckGofishVersion(version string) error {
+	_, err := gofishFieldSet(version)
+	return err
+}
+
+// gofishFieldSet returns the fields of foods gofish version reads.
+func gofishFieldSet(version string) (map[string]bool, error) {
+	target, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("gofish version: %w", err)
+	}
+
+	var best, oldest *semver.Version
+	for release := range gofishFields {
+		v := semver.MustParse(release)
+		if !v.GreaterThan(target) && (best == nil || v.GreaterThan(best)) {
+			best = v
+		}
+		if oldest == nil || v.LessThan(oldest) {
+			oldest = v
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("gofish version: fields read by %s are not known, only by %s and later", version, oldest.Original())
+	}
+
+	set := map[string]bool{}
+	for _, field := range gofishFields[best.Original()] {
+		set[field] = true
+	}
+	return set, nil
+}
+
+// normalizeField returns field as gluamapper matches it to the fields of
+// gofish: without underscores, in lower case.
+func normalizeField(field string) string {
+	return strings.ToLower(strings.ReplaceAll(field, "_", ""))
+}
+
+// incompatibleFields returns the fields set by src, the source of a food,
+// that gofish version does not read, and silently drops.
+func incompatibleFields(src, version string) ([]string, error) {
+	read, err := gofishFieldSet(version)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := rig.FoodFields(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var incompatible []string
+	for _, field := range fields {
+		if !read[normalizeField(field)] {
+			incompatible = append(incompatible, field)
+		}
+	}
+	sort.Strings(incompatible)
+	return incompatible, nil
+}
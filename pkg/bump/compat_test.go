@@ -0,0 +1,101 @@
+package bump
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/fishworks/gofish"
+)
+
+// knownFields returns the fields of foods gofish reads, as dotted paths in
+// lower case, from the structs it maps them to.
+func knownFields() map[string]bool {
+	known := map[string]bool{}
+	var walk func(prefix string, t reflect.Type)
+	walk = func(prefix string, t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			field := prefix + strings.ToLower(sf.Name)
+			known[field] = true
+
+			ft := sf.Type
+			for ft.Kind() == reflect.Slice || ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				walk(field+".", ft)
+			}
+		}
+	}
+	walk("", reflect.TypeOf(gofish.Food{}))
+	return known
+}
+
+// The newest field set must be the one of the gofish gfb is built with.
+func TestGofishFields(t *testing.T) {
+	var newest *semver.Version
+	for release := range gofishFields {
+		if v := semver.MustParse(release); newest == nil || v.GreaterThan(newest) {
+			newest = v
+		}
+	}
+	got, err := gofishFieldSet(newest.Original())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := knownFields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields of gofish %s = %v, want %v", newest, got, want)
+	}
+}
+
+func TestIncompatibleFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		version string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "compatible",
+			src:     testFood,
+			version: "0.14.0",
+		},
+		{
+			name:    "newer release",
+			src:     testFood,
+			version: "0.15.1",
+		},
+		{
+			name:    "unknown fields",
+			src:     strings.Replace(testFood, "version = version,", "version = version,\n    tags = { \"cli\" },", 1),
+			version: "0.14.0",
+			want:    []string{"tags"},
+		},
+		{
+			name:    "unknown release",
+			src:     testFood,
+			version: "0.13.0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			src:     testFood,
+			version: "latest",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := incompatibleFields(tt.src, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("incompatibleFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("incompatibleFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package bump
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severities of runs, from the most severe, selecting the recipients of
+// Options.EmailTo.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// severityRank orders the severities of runs.
+var severityRank = map[string]int{
+	SeverityError:   3,
+	SeverityWarning: 2,
+	SeverityInfo:    1,
+}
+
+// runSeverity returns the severity of the run of r, as the conclusion of its
+// check run: an error if any food failed or has an error finding, a warning
+// if any was skipped or has other findings, and info if foods were only
+// updated. It returns an empty string if all foods were up to date.
+func runSeverity(r *Report, pullRequest string) string {
+	switch conclusion, _ := checkConclusion(r); conclusion {
+	case "failure":
+		return SeverityError
+	case "neutral":
+		return SeverityWarning
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, res := range r.Results {
+		if res.Action == ActionUpdated {
+			return SeverityInfo
+		}
+	}
+	if len(pullRequest) > 0 {
+		return SeverityInfo
+	}
+	return ""
+}
+
+// emailReport emails the summary of the run, with the JSON report attached,
+// to the recipients of opts.EmailTo at its severity or below. Failing to send
+// is logged, not returned, so it never fails the run.
+func emailReport(r *Report, pullRequest string, opts Options) {
+	if len(opts.SMTPAddr) == 0 || len(opts.EmailTo) == 0 {
+		return
+	}
+	severity := runSeverity(r, pullRequest)
+	if len(severity) == 0 {
+		return
+	}
+	var to []string
+	seen := map[string]bool{}
+	for s, addrs := range opts.EmailTo {
+		if severityRank[s] > severityRank[severity] {
+			continue
+		}
+		for _, addr := range addrs {
+			if !seen[addr] {
+				seen[addr] = true
+				to = append(to, addr)
+			}
+		}
+	}
+	if len(to) == 0 {
+		return
+	}
+	sort.Strings(to)
+
+	msg, err := reportEmail(r, pullRequest, severity, to, opts)
+	if err == nil {
+		err = sendEmail(opts, to, msg)
+	}
+	if err != nil {
+		slog.Warn("emailing report failed", "phase", "notify", "smtp", opts.SMTPAddr, "error", err)
+		return
+	}
+	slog.Info("emailed report", "phase", "notify", "severity", severity, "recipients", len(to))
+}
+
+// reportEmail returns the message emailing the report of the run: its
+// summary as text, and the report as an attached JSON file.
+func reportEmail(r *Report, pullRequest, severity string, to []string, opts Options) ([]byte, error) {
+	_, title := checkConclusion(r)
+	var text strings.Builder
+	fmt.Fprintf(&text, "gfb run on %s\n\n", opts.Rig)
+	if len(pullRequest) > 0 {
+		fmt.Fprintf(&text, "Pull request: %s\n\n", pullRequest)
+	}
+	text.WriteString(markdownSummary(r))
+	r.mu.Lock()
+	if len(r.Findings) > 0 {
+		text.WriteString("\nFindings:\n")
+		for _, f := range r.Findings {
+			fmt.Fprintf(&text, "- %s (%s): %s\n", f.Food, f.Kind, firstLine(f.Message))
+		}
+	}
+	report, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, []byte(text.String()))
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/json"},
+		"Content-Disposition":       {`attachment; filename="gfb-report.json"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, report)
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", opts.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", fmt.Sprintf("[gfb %s] %s", severity, title)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// writeBase64 writes b to w in base64, in lines of 76 characters.
+func writeBase64(w io.Writer, b []byte) {
+	enc := base64.StdEncoding.EncodeToString(b)
+	for len(enc) > 76 {
+		fmt.Fprintf(w, "%s\r\n", enc[:76])
+		enc = enc[76:]
+	}
+	fmt.Fprintf(w, "%s\r\n", enc)
+}
+
+// sendEmail sends msg to to through opts.SMTPAddr, upgrading to TLS if the
+// server supports it, and authenticating if opts.SMTPUser is set.
+func sendEmail(opts Options, to []string, msg []byte) error {
+	var auth smtp.Auth
+	if len(opts.SMTPUser) > 0 {
+		host, _, err := net.SplitHostPort(opts.SMTPAddr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", opts.SMTPUser, opts.SMTPPassword, host)
+	}
+	return smtp.SendMail(opts.SMTPAddr, auth, opts.SMTPFrom, to, msg)
+}
@@ -0,0 +1,9 @@
+package bump
+
+const (
+	// ExitFailure is the exit status when any food failed permanently.
+	ExitFailure = 1
+	// ExitTransient is the exit status when every failure was transient, so
+	// the run may succeed if retried (EX_TEMPFAIL).
+	ExitTransient = 75
+)
@@ -0,0 +1,49 @@
+package bump
+
+import "time"
+
+// Kinds of events.
+const (
+	EventStarted     = "started"
+	EventResolved    = "resolved"
+	EventDownloading = "downloading"
+	EventUpdated     = "updated"
+	EventUpToDate    = "up-to-date"
+	EventSkipped     = "skipped"
+	EventFailed      = "failed"
+	EventNeedsHuman  = "needs-human"
+)
+
+// Event reports the progress of a run through a food. Each food starts with
+// an EventStarted and ends with one of EventUpdated, EventUpToDate,
+// EventSkipped, EventFailed or EventNeedsHuman, carrying its result.
+type Event struct {
+	Kind    string    `json:"kind"`
+	Time    time.Time `json:"time"`
+	Food    string    `json:"food"`
+	Version string    `json:"version"`
+	// Upstream is the latest upstream version, once resolved.
+	Upstream string `json:"upstream,omitempty"`
+	// URL is the package being downloaded.
+	URL    string  `json:"url,omitempty"`
+	Result *Result `json:"result,omitempty"`
+}
+
+// resultEvents maps the actions of results to the kind of the event ending
+// the food.
+var resultEvents = map[string]string{
+	ActionUpdated:    EventUpdated,
+	ActionUpToDate:   EventUpToDate,
+	ActionSkipped:    EventSkipped,
+	ActionError:      EventFailed,
+	ActionNeedsHuman: EventNeedsHuman,
+}
+
+// emit sends e to the event handler of the run, if any.
+func emit(opts Options, e Event) {
+	if opts.Events == nil {
+		return
+	}
+	e.Time = time.Now()
+	opts.Events(e)
+}
@@ -0,0 +1,110 @@
+package bump
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/arbourd/gfb/pkg/source"
+)
+
+// archiveExtensions are the extensions of the formats packages are released
+// in, longest first so .tar.gz is not taken for .gz.
+var archiveExtensions = []string{".tar.gz", ".tar.xz", ".tar.bz2", ".tar.zst", ".tgz", ".txz", ".tbz", ".zip", ".tar", ".gz", ".xz", ".bz2", ".zst"}
+
+// formatChange is the extension of package URLs replaced by a bump, as when
+// the upstream switched from tar.gz to zip.
+type formatChange struct {
+	Old string
+	New string
+}
+
+// splitExtension splits name into its stem and archive extension, which is
+// empty if name is not an archive.
+func splitExtension(name string) (string, string) {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return name[:len(name)-len(ext)], name[len(name)-len(ext):]
+		}
+	}
+	return name, ""
+}
+
+// switchedFormat returns the change of format of the package at url if
+// assets, the files of its release, do not hold it but hold a single archive
+// of the same name in another format.
+func switchedFormat(url string, assets []source.Asset) (formatChange, bool) {
+	stem, ext := splitExtension(path.Base(url))
+	if len(ext) == 0 {
+		return formatChange{}, false
+	}
+	var found []string
+	for _, a := range assets {
+		if a.Name == path.Base(url) {
+			return formatChange{}, false
+		}
+		if s, e := splitExtension(a.Name); s == stem && len(e) > 0 {
+			found = append(found, e)
+		}
+	}
+	if len(found) != 1 {
+		return formatChange{}, false
+	}
+	return formatChange{Old: ext, New: found[0]}, true
+}
+
+// droppedPlatform reports whether url, the bumped URL of a package released
+// on GitHub, is missing from assets, the files of its release, in any
+// format: the release dropped the platform of the package. It is false if
+// the assets are not known.
+func droppedPlatform(url string, assets []source.Asset) bool {
+	if org, _, _ := source.PackageRelease(url); len(org) == 0 || len(assets) == 0 {
+		return false
+	}
+	for _, a := range assets {
+		if a.Name == path.Base(url) {
+			return false
+		}
+	}
+	_, switched := switchedFormat(url, assets)
+	return !switched
+}
+
+// addFormatChange adds c to changes, unless it is already there. Packages
+// in the same format cannot switch to different formats, as the extension is
+// replaced throughout the food file.
+func addFormatChange(changes []formatChange, c formatChange) ([]formatChange, error) {
+	for _, e := range changes {
+		if e.Old != c.Old {
+			continue
+		}
+		if e.New != c.New {
+			return nil, fmt.Errorf("packages in %s switched to both %s and %s", c.Old, e.New, c.New)
+		}
+		return changes, nil
+	}
+	return append(changes, c), nil
+}
+
+// replaceFormats replaces the extensions of changes where they end a lua
+// string in src.
+func replaceFormats(src string, changes []formatChange) string {
+	for _, c := range changes {
+		for _, q := range []string{`"`, `'`} {
+			src = strings.ReplaceAll(src, c.Old+q, c.New+q)
+		}
+	}
+	return src
+}
+
+// replaceExtension replaces the extension of url if one of changes applies
+// to it.
+func replaceExtension(url string, changes []formatChange) string {
+	for _, c := range changes {
+		if strings.HasSuffix(url, c.Old) {
+			return strings.TrimSuffix(url, c.Old) + c.New
+		}
+	}
+	return url
+}
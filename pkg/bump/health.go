@@ -0,0 +1,83 @@
+package bump
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/arbourd/gfb/pkg/checksum"
+	"github.com/fishworks/gofish"
+)
+
+// checkURLs reports package URLs of the current version of each food that
+// upstream no longer serves.
+func checkURLs(ctx context.Context, feed []gofish.Food, opts Options) {
+	for _, f := range feed {
+		for _, pkg := range f.Packages {
+			status, err := urlStatus(ctx, pkg.URL, opts)
+			if err != nil {
+				slog.Warn("checking url failed", "food", f.Name, "version", f.Version, "phase", "health", "url", pkg.URL, "error", err)
+				continue
+			}
+			if status == http.StatusNotFound || status == http.StatusGone {
+				opts.Report.Add(f.Name, FindingDeadURL, fmt.Sprintf("%s/%s: %d: %s", pkg.OS, pkg.Arch, status, pkg.URL))
+			}
+		}
+	}
+}
+
+// urlStatus returns the status code url responds with, falling back to GET for
+// servers that do not allow HEAD.
+func urlStatus(ctx context.Context, url string, opts Options) (int, error) {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := httpClient(opts).Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			return resp.StatusCode, nil
+		}
+	}
+	return http.StatusMethodNotAllowed, nil
+}
+
+// checkAsset returns an error naming tag, the release url is an asset of, if
+// url does not exist, so it is not downloaded. Other failures are left for
+// the download to report.
+func checkAsset(ctx context.Context, url, tag string, opts Options) error {
+	status, err := urlStatus(ctx, checksum.Rewrite(url, opts.Mirrors), opts)
+	if err == nil && (status == http.StatusNotFound || status == http.StatusGone) {
+		return fmt.Errorf("asset not found for %s: %s", tag, url)
+	}
+	return nil
+}
+
+// sizeDropRatio is how many times smaller than the package it replaces a
+// package must be to be reported: likely an error page or a stub rather than
+// a release.
+const sizeDropRatio = 5
+
+// contentLength returns the size of the file at url its server reports for
+// a HEAD request, or -1 if it does not.
+func contentLength(ctx context.Context, url string, opts Options) int64 {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, checksum.Rewrite(url, opts.Mirrors), nil)
+	if err != nil {
+		return -1
+	}
+	resp, err := httpClient(opts).Do(req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1
+	}
+	return resp.ContentLength
+}
@@ -0,0 +1,55 @@
+package bump
+
+import (
+	"context"
+
+	"github.com/fishworks/gofish"
+)
+
+// BeforeBumpFunc is called with a food and the food it is about to be bumped
+// to, once its checksums are computed and it has been linted. Returning an
+// error vetoes the bump, and the food is skipped. Changes to the description
+// and homepage of new are written to the food file.
+type BeforeBumpFunc func(ctx context.Context, old gofish.Food, new *gofish.Food) error
+
+// AfterBumpFunc is called with the result of each food that was bumped.
+type AfterBumpFunc func(ctx context.Context, res Result)
+
+// OnErrorFunc is called with the result of each food that failed, and the
+// error it failed with.
+type OnErrorFunc func(ctx context.Context, res Result, err error)
+
+// Hooks are called while processing each food, in the order they were
+// added.
+type Hooks struct {
+	BeforeBump []BeforeBumpFunc
+	AfterBump  []AfterBumpFunc
+	OnError    []OnErrorFunc
+}
+
+// BeforeBump adds a hook called before each food is bumped, which may veto
+// the bump.
+func (b *Bumper) BeforeBump(fn BeforeBumpFunc) {
+	b.opts.Hooks.BeforeBump = append(b.opts.Hooks.BeforeBump, fn)
+}
+
+// AfterBump adds a hook called after each food is bumped.
+func (b *Bumper) AfterBump(fn AfterBumpFunc) {
+	b.opts.Hooks.AfterBump = append(b.opts.Hooks.AfterBump, fn)
+}
+
+// OnError adds a hook called when a food fails.
+func (b *Bumper) OnError(fn OnErrorFunc) {
+	b.opts.Hooks.OnError = append(b.opts.Hooks.OnError, fn)
+}
+
+// beforeBump calls the BeforeBump hooks, returning the error of the first to
+// veto the bump.
+func (h Hooks) beforeBump(ctx context.Context, old gofish.Food, new *gofish.Food) error {
+	for _, fn := range h.BeforeBump {
+		if err := fn(ctx, old, new); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package bump
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/arbourd/gfb/pkg/checksum"
+	"github.com/arbourd/gfb/pkg/sandbox"
+	"github.com/fishworks/gofish"
+)
+
+// smokeTestTimeout bounds each run of an executable by the smoke test.
+const smokeTestTimeout = 10 * time.Second
+
+// checkError is returned by sandboxInstall when the food installed, but
+// failed a check of the installed package. Finding is the kind of finding
+// reporting it.
+type checkError struct {
+	Finding string
+	Err     error
+}
+
+func (e checkError) Error() string {
+	return e.Err.Error()
+}
+
+func (e checkError) Unwrap() error {
+	return e.Err
+}
+
+// sandboxInstall installs the package of f, whose lua source is src, for the
+// current platform into a sandbox, and runs the test function of the food if
+// it defines one. The installed executables are smoke tested if enabled. The
+// sandbox is removed afterwards. Foods without a package for the current
+// platform are not installed.
+func sandboxInstall(ctx context.Context, f gofish.Food, src string, res *Result, opts Options) error {
+	pkg := f.GetPackage(runtime.GOOS, runtime.GOARCH)
+	if pkg == nil {
+		slog.Debug("no package to install", "food", f.Name, "version", f.Version, "phase", "install", "platform", runtime.GOOS+"/"+runtime.GOARCH)
+		return nil
+	}
+	mirrored := *pkg
+	mirrored.URL = checksum.Rewrite(pkg.URL, opts.Mirrors)
+
+	s, err := sandbox.Install(ctx, httpClient(opts), f, &mirrored)
+	if err != nil {
+		return fmt.Errorf("%s/%s: %w", pkg.OS, pkg.Arch, err)
+	}
+	defer s.Remove()
+	res.Bytes += s.Bytes
+	slog.Debug("installed in sandbox", "food", f.Name, "version", f.Version, "phase", "install", "prefix", s.Prefix)
+
+	tested, err := s.Test(ctx, src)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return checkError{FindingFoodTest, fmt.Errorf("%s/%s: %w", pkg.OS, pkg.Arch, err)}
+	}
+	if tested {
+		slog.Debug("food test passed", "food", f.Name, "version", f.Version, "phase", "install")
+	}
+
+	if opts.SmokeTest {
+		return smokeTest(ctx, s, f, pkg)
+	}
+	return nil
+}
+
+// smokeTest runs each executable of pkg installed in s with --version, then
+// version, until one prints the version of f. Packages without executables
+// pass.
+func smokeTest(ctx context.Context, s *sandbox.Sandbox, f gofish.Food, pkg *gofish.Package) error {
+	var tried []string
+	for _, r := range pkg.Resources {
+		if !r.Executable {
+			continue
+		}
+		for _, arg := range []string{"--version", "version"} {
+			runCtx, cancel := context.WithTimeout(ctx, smokeTestTimeout)
+			out, _ := s.Run(runCtx, r.InstallPath, arg)
+			cancel()
+			if strings.Contains(string(out), f.Version) {
+				slog.Debug("smoke test passed", "food", f.Name, "version", f.Version, "phase", "install", "executable", r.InstallPath, "arg", arg)
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+		tried = append(tried, r.InstallPath)
+	}
+	if len(tried) == 0 {
+		return nil
+	}
+	return checkError{FindingSmokeTest, fmt.Errorf("%s/%s: %s did not print version %s with --version or version", pkg.OS, pkg.Arch, strings.Join(tried, ", "), f.Version)}
+}
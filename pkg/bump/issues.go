@@ -0,0 +1,168 @@
+package bump
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/arbourd/gfb/pkg/rig"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/google/go-github/v39/github"
+)
+
+// stuckReason returns why res leaves its food unable to be bumped until the
+// food or its upstream is changed by hand, and whether it does. Transient
+// failures, and skips gfb was asked for, are not stuck.
+func stuckReason(res Result) (string, bool) {
+	switch res.Action {
+	case ActionError:
+		return res.Error, !res.Transient
+	case ActionSkipped:
+		if strings.HasPrefix(res.Reason, "cannot parse semver for") || strings.Contains(res.Reason, source.ErrNoUpstream.Error()) {
+			return res.Reason, true
+		}
+	}
+	return "", false
+}
+
+// diagnoses are hints for the reasons foods are stuck, matched in order by
+// substring.
+var diagnoses = []struct{ match, hint string }{
+	{"cannot parse semver for", "The latest upstream release is tagged in a scheme gfb cannot parse as a version. Set a constraint or release override for the food, or bump it by hand."},
+	{source.ErrNoUpstream.Error(), "gfb found no release upstream. The repository may have been renamed or deleted, or have stopped publishing releases; check the homepage of the food or set a release override."},
+	{"asset not found for", "The upstream release has no asset at a package URL of the food. The upstream may have renamed its assets; update the package URLs to the new names."},
+	{"downloading package", "A package of the food could not be downloaded. Check that its URL still exists for the new release."},
+	{"not found in food file", "The food file does not spell its version the way gfb replaces it. Write the version once, such as in a local variable, and build the package URLs from it."},
+	{"semver:", "The version of the food is not a semantic version, so gfb cannot compare it to upstream releases."},
+}
+
+// diagnose returns the hint for reason, or an empty string.
+func diagnose(reason string) string {
+	for _, d := range diagnoses {
+		if strings.Contains(reason, d.match) {
+			return d.hint
+		}
+	}
+	return ""
+}
+
+// failureIssueTitle returns the title of the tracking issue of food.
+func failureIssueTitle(food string) string {
+	return "Cannot bump " + food
+}
+
+// trackFailures opens or updates the tracking issue of a food stuck for at
+// least opts.FailureIssues runs, and closes it once the food is bumped or up
+// to date again. prev is the state of the food before res was recorded.
+// Failing to track is logged, not returned, so it never fails the food.
+func trackFailures(ctx context.Context, res Result, prev FoodState, opts Options) {
+	if opts.FailureIssues <= 0 || opts.State == nil || opts.DryRun {
+		return
+	}
+
+	var err error
+	if reason, ok := stuckReason(res); ok {
+		st, _, getErr := opts.State.Get(res.Food)
+		if getErr != nil {
+			err = getErr
+		} else if st.Stuck >= opts.FailureIssues {
+			err = openFailureIssue(ctx, res, reason, st, opts)
+		}
+	} else if prev.Stuck >= opts.FailureIssues && (res.Action == ActionUpdated || res.Action == ActionUpToDate) {
+		err = closeFailureIssue(ctx, res, opts)
+	}
+	if err != nil {
+		slog.Warn("tracking issue failed", "food", res.Food, "version", res.OldVersion, "phase", "report", "error", err)
+	}
+}
+
+// openFailureIssue opens the tracking issue of the food of res, or replaces
+// the diagnosis of the open one.
+func openFailureIssue(ctx context.Context, res Result, reason string, st FoodState, opts Options) error {
+	org, repo, err := rig.Repo(opts.RigOptions())
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "gfb could not bump `%s` in the last %d runs, since %s.\n\n", res.Food, st.Stuck, st.StuckSince.Format("2006-01-02"))
+	fmt.Fprintf(&b, "- Version: %s\n", res.OldVersion)
+	if len(res.Upstream) > 0 {
+		fmt.Fprintf(&b, "- Latest upstream: %s\n", res.Upstream)
+	}
+	if hint := diagnose(reason); len(hint) > 0 {
+		fmt.Fprintf(&b, "\n%s\n", hint)
+	}
+	fmt.Fprintf(&b, "\n```\n%s\n```\n\nThis issue is closed once the food is bumped or up to date again.\n", reason)
+	body := b.String()
+
+	title := failureIssueTitle(res.Food)
+	issue, err := findIssue(ctx, org, repo, title, opts)
+	if err != nil {
+		return err
+	}
+	if issue == nil {
+		_, _, err = opts.GithubClient.Issues.Create(ctx, org, repo, &github.IssueRequest{Title: &title, Body: &body})
+		if err != nil {
+			return fmt.Errorf("opening issue: %w", err)
+		}
+		slog.Info("opened tracking issue", "food", res.Food, "version", res.OldVersion, "phase", "report", "runs", st.Stuck)
+		return nil
+	}
+	if issue.GetBody() == body {
+		return nil
+	}
+	_, _, err = opts.GithubClient.Issues.Edit(ctx, org, repo, issue.GetNumber(), &github.IssueRequest{Body: &body})
+	if err != nil {
+		return fmt.Errorf("updating issue #%d: %w", issue.GetNumber(), err)
+	}
+	return nil
+}
+
+// closeFailureIssue closes the tracking issue of the food of res, if it has
+// one open.
+func closeFailureIssue(ctx context.Context, res Result, opts Options) error {
+	org, repo, err := rig.Repo(opts.RigOptions())
+	if err != nil {
+		return err
+	}
+	issue, err := findIssue(ctx, org, repo, failureIssueTitle(res.Food), opts)
+	if err != nil || issue == nil {
+		return err
+	}
+
+	comment := fmt.Sprintf("`%s` is up to date again.", res.Food)
+	if res.Action == ActionUpdated {
+		comment = fmt.Sprintf("`%s` was bumped to %s.", res.Food, res.NewVersion)
+	}
+	_, _, err = opts.GithubClient.Issues.CreateComment(ctx, org, repo, issue.GetNumber(), &github.IssueComment{Body: &comment})
+	if err != nil {
+		return fmt.Errorf("commenting on issue #%d: %w", issue.GetNumber(), err)
+	}
+	_, _, err = opts.GithubClient.Issues.Edit(ctx, org, repo, issue.GetNumber(), &github.IssueRequest{State: github.String("closed")})
+	if err != nil {
+		return fmt.Errorf("closing issue #%d: %w", issue.GetNumber(), err)
+	}
+	return nil
+}
+
+// findIssue returns the open issue of org/repo titled title, or nil.
+func findIssue(ctx context.Context, org, repo, title string, opts Options) (*github.Issue, error) {
+	listOpts := &github.IssueListByRepoOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		issues, resp, err := opts.GithubClient.Issues.ListByRepo(ctx, org, repo, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("listing issues: %w", err)
+		}
+		for _, issue := range issues {
+			if issue.GetTitle() == title && !issue.IsPullRequest() {
+				return issue, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		listOpts.Page = resp.NextPage
+	}
+}
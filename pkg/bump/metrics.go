@@ -0,0 +1,155 @@
+package bump
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of runs counted by Metrics.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeError   = "error"
+)
+
+// pushgatewayJob is the job runs push their metrics to the Pushgateway as.
+const pushgatewayJob = "gfb"
+
+// Metrics are the metrics of the runs of a process, exposed in the
+// Prometheus text format. Counters accumulate over the runs; gauges describe
+// the last run.
+type Metrics struct {
+	mu          sync.Mutex
+	foods       map[string]int
+	bytes       int64
+	apiRequests int
+	runs        map[string]int
+	duration    time.Duration
+	lastRun     time.Time
+	lastSuccess time.Time
+}
+
+// NewMetrics returns metrics with no runs recorded.
+func NewMetrics() *Metrics {
+	return &Metrics{foods: map[string]int{}, runs: map[string]int{}}
+}
+
+// observeRun records a run that took elapsed, reported r and ended with
+// outcome. r is nil if the run failed before loading the rig.
+func (m *Metrics) observeRun(r *Report, outcome string, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r != nil {
+		r.mu.Lock()
+		for _, res := range r.Results {
+			m.foods[res.Action]++
+			m.bytes += res.Bytes
+		}
+		r.mu.Unlock()
+	}
+	m.runs[outcome]++
+	m.duration = elapsed
+	m.lastRun = time.Now()
+	if outcome == OutcomeSuccess {
+		m.lastSuccess = m.lastRun
+	}
+}
+
+// apiRequest counts a request to the GitHub API.
+func (m *Metrics) apiRequest() {
+	m.mu.Lock()
+	m.apiRequests++
+	m.mu.Unlock()
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	labeled := func(name, label string, values map[string]int) {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s{%s=%q} %d\n", name, label, k, values[k])
+		}
+	}
+	timestamp := func(t time.Time) float64 {
+		if t.IsZero() {
+			return 0
+		}
+		return float64(t.UnixNano()) / 1e9
+	}
+
+	metric("gfb_foods_total", "counter", "Foods processed, by action.")
+	labeled("gfb_foods_total", "action", m.foods)
+	metric("gfb_downloaded_bytes_total", "counter", "Bytes downloaded while processing foods.")
+	fmt.Fprintf(&b, "gfb_downloaded_bytes_total %d\n", m.bytes)
+	metric("gfb_github_api_requests_total", "counter", "Requests made to the GitHub API.")
+	fmt.Fprintf(&b, "gfb_github_api_requests_total %d\n", m.apiRequests)
+	metric("gfb_runs_total", "counter", "Runs, by outcome.")
+	labeled("gfb_runs_total", "outcome", m.runs)
+	metric("gfb_run_duration_seconds", "gauge", "Duration of the last run.")
+	fmt.Fprintf(&b, "gfb_run_duration_seconds %g\n", m.duration.Seconds())
+	metric("gfb_last_run_timestamp_seconds", "gauge", "Time the last run finished.")
+	fmt.Fprintf(&b, "gfb_last_run_timestamp_seconds %g\n", timestamp(m.lastRun))
+	metric("gfb_last_success_timestamp_seconds", "gauge", "Time the last successful run finished.")
+	fmt.Fprintf(&b, "gfb_last_success_timestamp_seconds %g\n", timestamp(m.lastSuccess))
+
+	return b.WriteTo(w)
+}
+
+// ServeHTTP responds with the metrics, for Prometheus to scrape.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// Push replaces the metrics of the gfb job on the Prometheus Pushgateway at
+// gateway with m.
+func (m *Metrics) Push(ctx context.Context, client *http.Client, gateway string) error {
+	var b bytes.Buffer
+	m.WriteTo(&b)
+
+	u := strings.TrimSuffix(gateway, "/") + "/metrics/job/" + pushgatewayJob
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("pushing metrics to %s\n\nresponse code: %v", gateway, resp.StatusCode)
+	}
+	return nil
+}
+
+// countingTransport counts the requests made through it to the GitHub API.
+type countingTransport struct {
+	base    http.RoundTripper
+	metrics *Metrics
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.metrics.apiRequest()
+	return t.base.RoundTrip(req)
+}
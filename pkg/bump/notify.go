@@ -0,0 +1,127 @@
+package bump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// notifyMaxLines is the number of foods listed in each section of a
+// notification before the rest are counted.
+const notifyMaxLines = 15
+
+// discordMaxContent is the longest message Discord webhooks accept.
+const discordMaxContent = 2000
+
+// notify posts a summary of the run to each of opts.NotifyWebhooks. Runs
+// that only found foods up to date are not announced. Failing to notify is
+// logged, not returned, so it never fails the run.
+func notify(ctx context.Context, r *Report, pullRequest string, opts Options) {
+	if len(opts.NotifyWebhooks) == 0 {
+		return
+	}
+	text, ok := notification(r, pullRequest, opts)
+	if !ok {
+		return
+	}
+	for _, hook := range opts.NotifyWebhooks {
+		if err := postNotification(ctx, hook, text, opts); err != nil {
+			slog.Warn("notification failed", "phase", "notify", "host", webhookHost(hook), "error", err)
+		}
+	}
+}
+
+// notification returns the text announcing the run, and false if nothing
+// but foods up to date happened in it.
+func notification(r *Report, pullRequest string, opts Options) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := map[string]int{}
+	sections := map[string][]string{}
+	for _, res := range r.Results {
+		counts[res.Action]++
+		switch res.Action {
+		case ActionUpdated:
+			sections[res.Action] = append(sections[res.Action], fmt.Sprintf("%s %s → %s", res.Food, res.OldVersion, res.NewVersion))
+		case ActionError, ActionNeedsHuman:
+			sections[res.Action] = append(sections[res.Action], res.Food+": "+firstLine(res.Detail()))
+		}
+	}
+	if counts[ActionUpdated]+counts[ActionError]+counts[ActionNeedsHuman] == 0 && len(pullRequest) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "gfb run on %s: %d updated, %d skipped, %d failed, %d need a human, %d up to date\n",
+		opts.Rig, counts[ActionUpdated], counts[ActionSkipped], counts[ActionError], counts[ActionNeedsHuman], counts[ActionUpToDate])
+	if len(pullRequest) > 0 {
+		fmt.Fprintf(&b, "Pull request: %s\n", pullRequest)
+	}
+	for _, s := range []struct{ action, title string }{
+		{ActionUpdated, "Updated"},
+		{ActionError, "Failed"},
+		{ActionNeedsHuman, "Needs a human"},
+	} {
+		lines := sections[s.action]
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", s.title)
+		for i, line := range lines {
+			if i == notifyMaxLines {
+				fmt.Fprintf(&b, "• and %d more\n", len(lines)-i)
+				break
+			}
+			fmt.Fprintf(&b, "• %s\n", line)
+		}
+	}
+	return b.String(), true
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// postNotification posts text to the Slack or Discord incoming webhook at
+// hook. Discord webhooks are told apart by their host.
+func postNotification(ctx context.Context, hook, text string, opts Options) error {
+	payload := map[string]string{"text": text}
+	if isDiscordWebhook(hook) {
+		if r := []rune(text); len(r) > discordMaxContent {
+			text = string(r[:discordMaxContent-1]) + "…"
+		}
+		payload = map[string]string{"content": text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return deliver(ctx, httpClient(opts), hook, body, "")
+}
+
+// isDiscordWebhook reports whether hook is the URL of a Discord webhook.
+func isDiscordWebhook(hook string) bool {
+	host := webhookHost(hook)
+	for _, d := range []string{"discord.com", "discordapp.com"} {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookHost returns the host of hook, which is logged instead of the URL
+// holding the secret of the webhook.
+func webhookHost(hook string) string {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,184 @@
+package bump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/arbourd/gfb/pkg/rig"
+)
+
+// Plan is the set of changes resolved by the plan command, to be reviewed and
+// then made by the apply command.
+type Plan struct {
+	mu      sync.Mutex
+	Rig     string        `json:"rig"`
+	Created time.Time     `json:"created"`
+	Results []Result      `json:"results"`
+	Files   []PlannedFile `json:"files"`
+}
+
+// PlannedFile is a change to a single file of the rig.
+type PlannedFile struct {
+	// Path is the path of the file, relative to the root of the rig.
+	Path string `json:"path"`
+	// Old is the contents of the file when planned, or empty if the plan
+	// creates it.
+	Old  string      `json:"old"`
+	New  string      `json:"new"`
+	Mode os.FileMode `json:"mode"`
+}
+
+// addResult records the result of a food updated by the plan.
+func (p *Plan) addResult(res Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Results = append(p.Results, res)
+}
+
+// Update records the change c. Later changes to the same file replace its
+// new contents, keeping the contents from before the first.
+func (p *Plan) Update(ctx context.Context, c FileChange) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.Files {
+		if p.Files[i].Path == c.Path {
+			p.Files[i].New = c.New
+			return nil
+		}
+	}
+	p.Files = append(p.Files, PlannedFile{Path: c.Path, Old: c.Old, New: c.New, Mode: c.Mode})
+	return nil
+}
+
+// planned returns the new contents and mode of the file at path, relative to
+// the root of the rig, if the plan changes it.
+func (p *Plan) planned(path string) (string, os.FileMode, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, f := range p.Files {
+		if f.Path == path {
+			return f.New, f.Mode, true
+		}
+	}
+	return "", 0, false
+}
+
+// Write writes the plan as JSON to path.
+func (p *Plan) Write(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// readPlan reads the plan written to path.
+func readPlan(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+	p := &Plan{}
+	err = json.Unmarshal(b, p)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Apply makes the changes of the plan at path to a fresh clone of the rig,
+// and opens a pull request with them if requested. No change is made if any
+// file has changed since the plan was made.
+func Apply(ctx context.Context, path string, opts Options) error {
+	p, err := readPlan(path)
+	if err != nil {
+		return err
+	}
+	if p.Rig != opts.Rig {
+		return fmt.Errorf("plan is for rig %s, not %s", p.Rig, opts.Rig)
+	}
+
+	if opts.OpenPR {
+		SetupGithub(ctx, &opts)
+		unlock, err := lockRig(ctx, opts)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	_, cleanup, err := LoadRig(ctx, &opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	start := time.Now()
+	fs := opts.rig.Fs()
+	for _, f := range p.Files {
+		filePath := filepath.Join(opts.rig.Path, filepath.FromSlash(f.Path))
+		if len(f.Old) == 0 {
+			if _, err := fs.Stat(filePath); err == nil {
+				return fmt.Errorf("%s was created since the plan was made", f.Path)
+			}
+			continue
+		}
+		src, _, err := rig.ReadFoodFile(fs, filePath)
+		if err != nil {
+			return err
+		}
+		if src != f.Old {
+			return fmt.Errorf("%s has changed since the plan was made", f.Path)
+		}
+	}
+
+	for _, f := range p.Files {
+		filePath := filepath.Join(opts.rig.Path, filepath.FromSlash(f.Path))
+		err := writeFoodFile(ctx, filePath, f.Old, f.New, f.Mode, opts)
+		if err != nil {
+			return err
+		}
+	}
+	if err := flushUpdaters(ctx, opts); err != nil {
+		return err
+	}
+	for _, res := range p.Results {
+		if len(opts.AuditLog) > 0 {
+			err := appendAudit(opts.AuditLog, res)
+			if err != nil {
+				return err
+			}
+		}
+		opts.Report.SetResult(res)
+	}
+	opts.Report.PrintSummary(time.Since(start))
+
+	var pullRequest string
+	if opts.OpenPR {
+		pullRequest, err = openPullRequest(ctx, opts)
+		if err != nil {
+			return err
+		}
+	}
+	if path := os.Getenv("GITHUB_OUTPUT"); len(path) > 0 {
+		err := writeOutputs(path, opts.Report, pullRequest, opts)
+		if err != nil {
+			return err
+		}
+	}
+	notify(ctx, opts.Report, pullRequest, opts)
+	postReport(ctx, opts.Report, pullRequest, opts)
+	emailReport(opts.Report, pullRequest, opts)
+	return nil
+}
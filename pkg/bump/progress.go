@@ -0,0 +1,40 @@
+package bump
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// progress logs a running count of processed foods, with an estimate of the
+// time remaining based on the average time spent per food so far.
+type progress struct {
+	total int
+	done  int
+	start time.Time
+}
+
+func newProgress(total int) *progress {
+	return &progress{total: total, start: time.Now()}
+}
+
+func (p *progress) step(res Result) {
+	p.done++
+	avg := time.Since(p.start) / time.Duration(p.done)
+	eta := avg * time.Duration(p.total-p.done)
+
+	var outcome string
+	switch res.Action {
+	case ActionUpdated:
+		outcome = "updated to " + res.NewVersion
+	case ActionUpToDate:
+		outcome = "up to date"
+	case ActionSkipped:
+		outcome = "skipped"
+	case ActionError:
+		outcome = "failed"
+	case ActionNeedsHuman:
+		outcome = "needs a human"
+	}
+	slog.Info(fmt.Sprintf("[%d/%d] %s ... %s", p.done, p.total, res.Food, outcome), "food", res.Food, "version", res.OldVersion, "phase", "progress", "eta", eta.Round(time.Second).String())
+}
@@ -0,0 +1,177 @@
+package bump
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/fishworks/gofish"
+)
+
+// versionIndexes returns the indexes of the occurrences of version in s
+// that are not part of a longer version, such as 1.2 in 1.2.3 or 11.2.
+func versionIndexes(s, version string) []int {
+	isVersionByte := func(b byte) bool { return b == '.' || (b >= '0' && b <= '9') }
+
+	var idx []int
+	for i := 0; len(version) > 0; {
+		j := strings.Index(s[i:], version)
+		if j < 0 {
+			break
+		}
+		start, end := i+j, i+j+len(version)
+		before := start > 0 && isVersionByte(s[start-1])
+		// A trailing dot ends a sentence, not a version, unless a digit
+		// follows it.
+		after := end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' && end+1 < len(s) && s[end+1] >= '0' && s[end+1] <= '9')
+		if !before && !after {
+			idx = append(idx, start)
+		}
+		i = start + 1
+	}
+	return idx
+}
+
+// replaceVersion replaces the occurrences of old in s that are not part of
+// a longer version with new.
+func replaceVersion(s, old, new string) string {
+	idx := versionIndexes(s, old)
+	if len(idx) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, i := range idx {
+		b.WriteString(s[last:i])
+		b.WriteString(new)
+		last = i + len(old)
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// versionChange is a version replaced by a bump: the version of the food,
+// or the version of the upstream of some of its packages.
+type versionChange struct {
+	Old string
+	New string
+}
+
+// replaceVersions applies changes to s in turn.
+func replaceVersions(s string, changes []versionChange) string {
+	for _, c := range changes {
+		s = replaceVersion(s, c.Old, c.New)
+	}
+	return s
+}
+
+// rewriteFood returns src, the source of f, rewritten to the food bumped to:
+// the versions of changes, and the checksums of its packages, replaced. It
+// fails rather than guess if a checksum is shared by packages whose new
+// checksums differ, or appears in src other than once per package.
+func rewriteFood(src string, f, bumped gofish.Food, changes []versionChange) (string, error) {
+	for _, c := range changes {
+		if len(versionIndexes(src, c.Old)) == 0 {
+			return "", fmt.Errorf("version %s not found in food file", c.Old)
+		}
+	}
+	updated := replaceVersions(src, changes)
+
+	shas := map[string]string{}
+	uses := map[string]int{}
+	for i, p := range f.Packages {
+		if len(p.SHA256) == 0 {
+			return "", fmt.Errorf("%s/%s has no checksum to replace", p.OS, p.Arch)
+		}
+		newSHA := bumped.Packages[i].SHA256
+		if prev, ok := shas[p.SHA256]; ok && prev != newSHA {
+			return "", fmt.Errorf("packages share checksum %s, but their new checksums differ", p.SHA256)
+		}
+		shas[p.SHA256] = newSHA
+		uses[p.SHA256]++
+	}
+	for old, n := range uses {
+		if count := strings.Count(updated, old); count != n {
+			return "", fmt.Errorf("checksum %s appears %d times in food file, expected %d", old, count, n)
+		}
+	}
+	for old, newSHA := range shas {
+		updated = strings.ReplaceAll(updated, old, newSHA)
+	}
+	return updated, nil
+}
+
+// expectedFood returns f as it should parse after rewriteFood bumped it:
+// the versions of changes replaced in each of its strings, and the version
+// and checksums of bumped.
+func expectedFood(f, bumped gofish.Food, changes []versionChange) gofish.Food {
+	r := func(s string) string { return replaceVersions(s, changes) }
+
+	e := f
+	e.Name, e.Rig, e.Description, e.License = r(f.Name), r(f.Rig), r(f.Description), r(f.License)
+	e.Homepage, e.Caveats, e.Version = r(f.Homepage), r(f.Caveats), bumped.Version
+	e.PreInstallScript, e.PostInstallScript = r(f.PreInstallScript), r(f.PostInstallScript)
+	// Empty tables are built empty rather than nil, so that the comparison
+	// with the rewritten food does not see them as changed.
+	if f.Packages != nil {
+		e.Packages = make([]*gofish.Package, 0, len(f.Packages))
+	}
+	for i, p := range f.Packages {
+		ep := *p
+		ep.URL = r(p.URL)
+		ep.SHA256 = bumped.Packages[i].SHA256
+		if p.Mirrors != nil {
+			ep.Mirrors = make([]string, 0, len(p.Mirrors))
+		}
+		for _, m := range p.Mirrors {
+			ep.Mirrors = append(ep.Mirrors, r(m))
+		}
+		if p.Resources != nil {
+			ep.Resources = make([]*gofish.Resource, 0, len(p.Resources))
+		}
+		for _, res := range p.Resources {
+			eres := *res
+			eres.Path, eres.InstallPath = r(res.Path), r(res.InstallPath)
+			ep.Resources = append(ep.Resources, &eres)
+		}
+		e.Packages = append(e.Packages, &ep)
+	}
+	return e
+}
+
+// matchLineEndings returns src with the line endings of old: CRLF if every
+// line of old ends with one, and a final newline only if old has one. src is
+// returned unchanged if old is empty.
+func matchLineEndings(old, src string) string {
+	if len(old) == 0 {
+		return src
+	}
+	lines := strings.Count(old, "\n")
+	if lines > 0 && strings.Count(old, "\r\n") == lines {
+		src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\n", "\r\n")
+	}
+
+	eol := "\n"
+	if strings.HasSuffix(old, "\r\n") || strings.HasSuffix(src, "\r\n") {
+		eol = "\r\n"
+	}
+	switch {
+	case strings.HasSuffix(old, "\n") && !strings.HasSuffix(src, "\n"):
+		src += eol
+	case !strings.HasSuffix(old, "\n") && strings.HasSuffix(src, "\n"):
+		src = strings.TrimSuffix(strings.TrimSuffix(src, "\n"), "\r")
+	}
+	return src
+}
+
+// foodDiff returns the names of the fields of got that differ from want.
+func foodDiff(want, got gofish.Food) []string {
+	var fields []string
+	wv, gv := reflect.ValueOf(want), reflect.ValueOf(got)
+	for i := 0; i < wv.NumField(); i++ {
+		if !reflect.DeepEqual(wv.Field(i).Interface(), gv.Field(i).Interface()) {
+			fields = append(fields, strings.ToLower(wv.Type().Field(i).Name))
+		}
+	}
+	return fields
+}
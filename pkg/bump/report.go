@@ -0,0 +1,274 @@
+package bump
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ActionUpdated  = "updated"
+	ActionUpToDate = "up-to-date"
+	ActionSkipped  = "skipped"
+	ActionError    = "error"
+	// ActionNotAttempted is the action of foods not reached before the run deadline.
+	ActionNotAttempted = "not-attempted"
+	// ActionNeedsHuman is the action of foods whose bump cannot be made
+	// automatically, such as when the new release drops a platform.
+	ActionNeedsHuman = "needs-human"
+)
+
+const (
+	FindingDeprecationCandidate = "deprecation-candidate"
+	FindingHeldMajor            = "held-major"
+	FindingDeadURL              = "dead-url"
+	FindingDuplicateUpstream    = "duplicate-upstream"
+	FindingDuplicateBinary      = "duplicate-binary"
+	FindingConflictingPath      = "conflicting-path"
+	FindingLint                 = "lint"
+	FindingChecksumMismatch     = "checksum-mismatch"
+	FindingDowngrade            = "downgrade"
+	FindingChecksumDrift        = "checksum-drift"
+	FindingVersionMismatch      = "version-mismatch"
+	FindingInstall              = "install"
+	FindingSmokeTest            = "smoke-test"
+	FindingFoodTest             = "food-test"
+	FindingMissingPath          = "missing-path"
+	FindingSizeDrop             = "size-drop"
+	FindingPlatformRegression   = "platform-regression"
+	FindingGofishCompat         = "gofish-compat"
+	FindingYankedRelease        = "yanked-release"
+)
+
+var findingSections = map[string]string{
+	FindingDeprecationCandidate: "Deprecation candidates",
+	FindingHeldMajor:            "Held major version bumps",
+	FindingDeadURL:              "Dead package URLs",
+	FindingDuplicateUpstream:    "Foods sharing an upstream",
+	FindingDuplicateBinary:      "Foods sharing a binary name",
+	FindingConflictingPath:      "Foods sharing an install path",
+	FindingLint:                 "Lint errors",
+	FindingChecksumMismatch:     "Checksum mismatches",
+	FindingDowngrade:            "Upstream releases older than the food",
+	FindingChecksumDrift:        "Packages changed without a new version",
+	FindingVersionMismatch:      "Package URLs of another version",
+	FindingInstall:              "Failed sandbox installs",
+	FindingSmokeTest:            "Binaries not reporting their new version",
+	FindingFoodTest:             "Failed food tests",
+	FindingMissingPath:          "Resources missing from packages",
+	FindingSizeDrop:             "Packages much smaller than the previous version",
+	FindingPlatformRegression:   "Releases dropping platforms of the food",
+	FindingGofishCompat:         "Foods gofish clients cannot fully read",
+	FindingYankedRelease:        "Releases deleted upstream",
+}
+
+// Report collects the result of processing each food, and findings about
+// foods that need a maintainer's attention but are not errors in processing.
+type Report struct {
+	mu       sync.Mutex
+	Results  []Result  `json:"results"`
+	Findings []Finding `json:"findings"`
+}
+
+// Result is the outcome of processing a single food.
+type Result struct {
+	Food       string          `json:"food"`
+	Action     string          `json:"action"`
+	Reason     string          `json:"reason,omitempty"`
+	OldVersion string          `json:"old_version"`
+	NewVersion string          `json:"new_version,omitempty"`
+	ReleaseURL string          `json:"release_url,omitempty"`
+	Packages   []PackageChange `json:"packages,omitempty"`
+	LintErrors []string        `json:"lint_errors,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	// Transient is set for errors that may not recur if the food is retried.
+	Transient bool `json:"transient,omitempty"`
+	// Bytes is the number of bytes downloaded while processing the food.
+	Bytes int64 `json:"bytes"`
+	// Upstream is the latest upstream version, and LastUpstream the latest
+	// upstream version seen by the last run if it has changed since.
+	Upstream     string `json:"upstream,omitempty"`
+	LastUpstream string `json:"last_upstream,omitempty"`
+}
+
+// Detail describes why the food was skipped or failed.
+func (r Result) Detail() string {
+	switch r.Action {
+	case ActionSkipped, ActionNeedsHuman:
+		if len(r.LintErrors) > 0 {
+			return r.Reason + ": " + strings.Join(r.LintErrors, "; ")
+		}
+		return r.Reason
+	case ActionError:
+		return r.Error
+	}
+	return ""
+}
+
+// PackageChange is the change made to a single package of an updated food.
+type PackageChange struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	OldURL    string `json:"old_url"`
+	URL       string `json:"url"`
+	OldSHA256 string `json:"old_sha256"`
+	SHA256    string `json:"sha256"`
+	// Size is the size of the package in bytes, and OldSize the size of the
+	// package it replaces, if they are known.
+	Size    int64 `json:"size,omitempty"`
+	OldSize int64 `json:"old_size,omitempty"`
+}
+
+type Finding struct {
+	Food    string `json:"food"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// SetResult records the result of processing a food, replacing the result of
+// an earlier attempt at the same food.
+func (r *Report) SetResult(res Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.Results {
+		if r.Results[i].Food == res.Food {
+			r.Results[i] = res
+			return
+		}
+	}
+	r.Results = append(r.Results, res)
+}
+
+func (r *Report) Add(food, kind, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Findings = append(r.Findings, Finding{Food: food, Kind: kind, Message: message})
+}
+
+func (r *Report) Print() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sections := map[string][]Finding{}
+	var kinds []string
+	for _, f := range r.Findings {
+		if _, ok := sections[f.Kind]; !ok {
+			kinds = append(kinds, f.Kind)
+		}
+		sections[f.Kind] = append(sections[f.Kind], f)
+	}
+
+	for _, kind := range kinds {
+		title, ok := findingSections[kind]
+		if !ok {
+			title = kind
+		}
+		for _, f := range sections[kind] {
+			slog.Warn(title, "food", f.Food, "phase", "report", "finding", f.Kind, "message", f.Message)
+		}
+	}
+}
+
+// PrintSummary logs the number of foods with each outcome, the reasons foods
+// were skipped, and which foods failed.
+func (r *Report) PrintSummary(elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := map[string]int{}
+	reasons := map[string]int{}
+	var reasonOrder, failed, transient, needsHuman []string
+	var bytes int64
+	for _, res := range r.Results {
+		counts[res.Action]++
+		bytes += res.Bytes
+		switch res.Action {
+		case ActionSkipped:
+			if _, ok := reasons[res.Reason]; !ok {
+				reasonOrder = append(reasonOrder, res.Reason)
+			}
+			reasons[res.Reason]++
+		case ActionError:
+			if res.Transient {
+				transient = append(transient, res.Food)
+			} else {
+				failed = append(failed, res.Food)
+			}
+		case ActionNeedsHuman:
+			needsHuman = append(needsHuman, res.Food)
+		}
+	}
+
+	slog.Info("summary",
+		"updated", counts[ActionUpdated],
+		"up_to_date", counts[ActionUpToDate],
+		"skipped", counts[ActionSkipped],
+		"failed", counts[ActionError],
+		"needs_human", counts[ActionNeedsHuman],
+		"not_attempted", counts[ActionNotAttempted],
+		"bytes_downloaded", bytes,
+		"elapsed", elapsed.Round(time.Second).String(),
+	)
+	for _, reason := range reasonOrder {
+		slog.Info("summary: skipped", "reason", reason, "count", reasons[reason])
+	}
+	if len(failed) > 0 {
+		slog.Info("summary: failed", "foods", strings.Join(failed, ","))
+	}
+	if len(transient) > 0 {
+		slog.Info("summary: failed transiently", "foods", strings.Join(transient, ","))
+	}
+	if len(needsHuman) > 0 {
+		slog.Info("summary: needs a human", "foods", strings.Join(needsHuman, ","))
+	}
+}
+
+// ExitStatus returns the exit status of a run: 0 if no more than maxErrors
+// foods failed, otherwise ExitFailure if any food failed permanently, or
+// ExitTransient if all failures were transient.
+func (r *Report) ExitStatus(maxErrors int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures := 0
+	status := 0
+	for _, res := range r.Results {
+		if res.Action != ActionError {
+			continue
+		}
+		failures++
+		if !res.Transient {
+			status = ExitFailure
+		} else if status == 0 {
+			status = ExitTransient
+		}
+	}
+
+	if failures <= maxErrors {
+		return 0
+	}
+	return status
+}
+
+// WriteJSON writes the report as JSON to path, or to stdout if path is -.
+func (r *Report) WriteJSON(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,115 @@
+package bump
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// sarifLevels maps the kinds of finding included in SARIF output to their
+// result level.
+var sarifLevels = map[string]string{
+	FindingLint:               "warning",
+	FindingChecksumMismatch:   "error",
+	FindingDeadURL:            "error",
+	FindingChecksumDrift:      "error",
+	FindingVersionMismatch:    "warning",
+	FindingInstall:            "error",
+	FindingSmokeTest:          "error",
+	FindingFoodTest:           "error",
+	FindingMissingPath:        "error",
+	FindingSizeDrop:           "warning",
+	FindingPlatformRegression: "warning",
+	FindingGofishCompat:       "error",
+	FindingYankedRelease:      "warning",
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+// writeSARIF writes the report's validation findings as a SARIF log to path,
+// for upload to GitHub code scanning against the rig.
+func writeSARIF(path string, r *Report, opts Options) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "gfb",
+			InformationURI: "https://github.com/arbourd/gfb",
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := map[string]bool{}
+	for _, f := range r.Findings {
+		level, ok := sarifLevels[f.Kind]
+		if !ok {
+			continue
+		}
+		if !rules[f.Kind] {
+			rules[f.Kind] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: f.Kind, ShortDescription: sarifMessage{Text: findingSections[f.Kind]}})
+		}
+
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = foodFileURI(f.Food, opts)
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Kind,
+			Level:     level,
+			Message:   sarifMessage{Text: f.Food + ": " + f.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	b, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
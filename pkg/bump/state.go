@@ -0,0 +1,158 @@
+package bump
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// foodsBucket is the bucket of the state store holding the state of each food.
+var foodsBucket = []byte("foods")
+
+const (
+	// backoffFailures is the number of consecutive failures after which a
+	// food is no longer processed on every run.
+	backoffFailures = 3
+	// maxBackoff is the longest a failing food is not processed for.
+	maxBackoff = 7 * 24 * time.Hour
+)
+
+// State is the store of what earlier runs saw of each food, persisted between
+// runs.
+type State struct {
+	db *bolt.DB
+}
+
+// FoodState is what earlier runs saw of a single food.
+type FoodState struct {
+	// Version is the version of the food when it was last checked.
+	Version string `json:"version"`
+	// Upstream is the latest upstream version when the food was last checked.
+	Upstream string    `json:"upstream,omitempty"`
+	Checked  time.Time `json:"checked"`
+	Bumped   time.Time `json:"bumped,omitempty"`
+	// UpToDate is when the food was last confirmed to be at the latest
+	// upstream version.
+	UpToDate time.Time `json:"up_to_date,omitempty"`
+	// Failure is the error of the last failure, and Failures the number of
+	// attempts that failed since the food was last checked successfully.
+	Failure     string    `json:"failure,omitempty"`
+	FailureTime time.Time `json:"failure_time,omitempty"`
+	Failures    int       `json:"failures,omitempty"`
+	// Stuck is the number of consecutive runs the food could not be bumped
+	// without a change to it, and StuckSince when the first of them ran.
+	Stuck      int       `json:"stuck,omitempty"`
+	StuckSince time.Time `json:"stuck_since,omitempty"`
+}
+
+// OpenState opens the state store at path, creating it if needed.
+func OpenState(path string) (*State, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening state %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(foodsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening state %s: %w", path, err)
+	}
+	return &State{db: db}, nil
+}
+
+// Close closes the state store.
+func (s *State) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the state of food, and whether any run has seen it.
+func (s *State) Get(food string) (FoodState, bool, error) {
+	var fs FoodState
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(foodsBucket).Get([]byte(food))
+		if b == nil {
+			return nil
+		}
+		ok = true
+		return json.Unmarshal(b, &fs)
+	})
+	if err != nil {
+		return fs, false, fmt.Errorf("reading state of %s: %w", food, err)
+	}
+	return fs, ok, nil
+}
+
+// Record updates the state of the food with the result of processing it, and
+// returns its state before the update.
+func (s *State) Record(res Result) (FoodState, error) {
+	prev, _, err := s.Get(res.Food)
+	if err != nil {
+		return prev, err
+	}
+	if res.Action == ActionNotAttempted {
+		return prev, nil
+	}
+
+	now := time.Now().UTC()
+	fs := prev
+	fs.Version = res.OldVersion
+	fs.Checked = now
+	if len(res.Upstream) > 0 {
+		fs.Upstream = res.Upstream
+	}
+	switch res.Action {
+	case ActionUpToDate:
+		fs.UpToDate = now
+	case ActionUpdated:
+		fs.Version = res.NewVersion
+		fs.Bumped = now
+		fs.UpToDate = now
+	case ActionError:
+		fs.Failure = res.Error
+		fs.FailureTime = now
+		fs.Failures++
+	}
+	if _, ok := stuckReason(res); ok {
+		if fs.Stuck == 0 {
+			fs.StuckSince = now
+		}
+		fs.Stuck++
+	}
+	if res.Action == ActionUpToDate || res.Action == ActionUpdated {
+		fs.Failure = ""
+		fs.FailureTime = time.Time{}
+		fs.Failures = 0
+		fs.Stuck = 0
+		fs.StuckSince = time.Time{}
+	}
+
+	b, err := json.Marshal(fs)
+	if err != nil {
+		return prev, err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(foodsBucket).Put([]byte(res.Food), b)
+	})
+	if err != nil {
+		return prev, fmt.Errorf("writing state of %s: %w", res.Food, err)
+	}
+	return prev, nil
+}
+
+// backoff returns how long after its last failure a food is not processed
+// for. It doubles with each consecutive failure after backoffFailures.
+func (fs FoodState) backoff() time.Duration {
+	if fs.Failures < backoffFailures {
+		return 0
+	}
+	d := time.Hour << (fs.Failures - backoffFailures)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
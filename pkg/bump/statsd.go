@@ -0,0 +1,82 @@
+package bump
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsD sends the timing and outcome of each food to a StatsD server over
+// UDP. Sends are fire and forget: lost packets and unreachable servers never
+// fail the run.
+type StatsD struct {
+	// DogStatsD tags metrics with the food, its action and the rig in the
+	// DogStatsD format, rather than naming metrics after the food.
+	DogStatsD bool
+	// Tags are added to every metric sent in the DogStatsD format, such as
+	// env:prod.
+	Tags []string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialStatsD returns a StatsD client sending to the server at addr, a
+// host:port.
+func DialStatsD(addr string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	return &StatsD{conn: conn}, nil
+}
+
+// Close closes the connection to the server.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// observeFood sends the outcome of res, the time processing it took and the
+// bytes downloaded for it, as gfb.food.<food>.result.<action>,
+// gfb.food.<food>.duration and gfb.food.<food>.downloaded_bytes. The food is
+// a tag rather than part of the name with DogStatsD.
+func (s *StatsD) observeFood(res Result, elapsed time.Duration, opts Options) {
+	prefix := "gfb.food."
+	tags := []string{"food:" + res.Food, "action:" + res.Action}
+	if len(opts.Rig) > 0 {
+		tags = append(tags, "rig:"+opts.Rig)
+	}
+	if !s.DogStatsD {
+		prefix += statsdNameRegex.ReplaceAllString(res.Food, "_") + "."
+	}
+
+	s.send(prefix+"result."+res.Action, "1|c", tags)
+	s.send(prefix+"duration", fmt.Sprintf("%d|ms", elapsed.Milliseconds()), tags)
+	if res.Bytes > 0 {
+		s.send(prefix+"downloaded_bytes", fmt.Sprintf("%d|c", res.Bytes), tags)
+	}
+}
+
+// statsdNameRegex matches the characters of food names replaced in the
+// names of StatsD metrics.
+var statsdNameRegex = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// send sends the metric name with value, a value and type such as 1|c,
+// tagged with tags in the DogStatsD format.
+func (s *StatsD) send(name, value string, tags []string) {
+	line := name + ":" + value
+	if s.DogStatsD {
+		all := append(tags[:len(tags):len(tags)], s.Tags...)
+		for i, t := range all {
+			all[i] = strings.NewReplacer("|", "_", ",", "_", "#", "_").Replace(t)
+		}
+		line += "|#" + strings.Join(all, ",")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.Write([]byte(line))
+}
@@ -0,0 +1,219 @@
+package bump
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arbourd/gfb/pkg/rig"
+	"github.com/google/go-github/v39/github"
+	"github.com/spf13/afero"
+)
+
+// FileChange is a change to a single file of the rig computed by a run.
+type FileChange struct {
+	// Path is the slash-separated path of the file, relative to the root of
+	// the rig.
+	Path string
+	// Old is the contents of the file before the change, or empty if the
+	// change creates it.
+	Old  string
+	New  string
+	Mode os.FileMode
+}
+
+// Updater applies the changes computed by a run to a target.
+type Updater interface {
+	Update(ctx context.Context, c FileChange) error
+}
+
+// Flusher is implemented by updaters that batch the changes of a run, and
+// apply them once the run has computed them all.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// FsUpdater writes changes to the checkout of the rig at Root.
+type FsUpdater struct {
+	Fs   afero.Fs
+	Root string
+}
+
+func (u FsUpdater) Update(ctx context.Context, c FileChange) error {
+	return rig.WriteFoodFile(u.Fs, filepath.Join(u.Root, filepath.FromSlash(c.Path)), c.New, c.Mode)
+}
+
+// PatchUpdater writes changes to W as unified diffs.
+type PatchUpdater struct {
+	W io.Writer
+}
+
+func (u PatchUpdater) Update(ctx context.Context, c FileChange) error {
+	aName := "a/" + c.Path
+	if len(c.Old) == 0 {
+		aName = "/dev/null"
+	}
+	_, err := io.WriteString(u.W, unifiedDiff(aName, "b/"+c.Path, c.Old, c.New))
+	return err
+}
+
+// GithubUpdater commits the changes of a run to Branch of Org/Repo through
+// the GitHub Git Data API, without a checkout of the rig. Changes are batched
+// until Flush, which makes them in a single commit. The commit fails if any
+// file has changed on the branch since it was read.
+type GithubUpdater struct {
+	Client *github.Client
+	Org    string
+	Repo   string
+	// Branch is the branch to commit to, or the default branch if empty.
+	Branch  string
+	Message string
+	Author  *github.CommitAuthor
+
+	changes []FileChange
+}
+
+// Update batches c, merging it into an earlier change to the same file.
+func (u *GithubUpdater) Update(ctx context.Context, c FileChange) error {
+	for i, prev := range u.changes {
+		if prev.Path == c.Path {
+			u.changes[i].New, u.changes[i].Mode = c.New, c.Mode
+			return nil
+		}
+	}
+	u.changes = append(u.changes, c)
+	return nil
+}
+
+// Flush commits the batched changes to the branch.
+func (u *GithubUpdater) Flush(ctx context.Context) error {
+	changes := u.changes
+	u.changes = nil
+	if len(changes) == 0 {
+		return nil
+	}
+
+	branch := u.Branch
+	if len(branch) == 0 {
+		repo, _, err := u.Client.Repositories.Get(ctx, u.Org, u.Repo)
+		if err != nil {
+			return fmt.Errorf("resolving default branch: %w", err)
+		}
+		branch = repo.GetDefaultBranch()
+	}
+	ref, _, err := u.Client.Git.GetRef(ctx, u.Org, u.Repo, "refs/heads/"+branch)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", branch, err)
+	}
+	head, _, err := u.Client.Git.GetCommit(ctx, u.Org, u.Repo, ref.GetObject().GetSHA())
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", branch, err)
+	}
+	tree, _, err := u.Client.Git.GetTree(ctx, u.Org, u.Repo, head.GetTree().GetSHA(), true)
+	if err != nil {
+		return fmt.Errorf("reading tree of %s: %w", branch, err)
+	}
+	if tree.GetTruncated() {
+		return fmt.Errorf("reading tree of %s: too many files", branch)
+	}
+	blobs := map[string]string{}
+	for _, e := range tree.Entries {
+		blobs[e.GetPath()] = e.GetSHA()
+	}
+
+	var entries []*github.TreeEntry
+	var paths []string
+	for _, c := range changes {
+		sha, exists := blobs[c.Path]
+		if (len(c.Old) == 0 && exists) || (len(c.Old) > 0 && sha != blobSHA(c.Old)) {
+			return fmt.Errorf("committing %s: file has changed on %s", c.Path, branch)
+		}
+		mode := "100644"
+		if c.Mode&0o111 != 0 {
+			mode = "100755"
+		}
+		entries = append(entries, &github.TreeEntry{
+			Path:    github.String(c.Path),
+			Mode:    github.String(mode),
+			Type:    github.String("blob"),
+			Content: github.String(c.New),
+		})
+		paths = append(paths, c.Path)
+	}
+
+	message := u.Message
+	if len(message) == 0 {
+		message = "Update " + strings.Join(paths, ", ")
+	}
+	commit, err := rig.CommitTree(ctx, u.Client, u.Org, u.Repo, head, entries, message, u.Author)
+	if err != nil {
+		return err
+	}
+	_, _, err = u.Client.Git.UpdateRef(ctx, u.Org, u.Repo, &github.Reference{
+		Ref:    ref.Ref,
+		Object: &github.GitObject{SHA: commit.SHA},
+	}, false)
+	if err != nil {
+		return fmt.Errorf("updating %s: %w", branch, err)
+	}
+	return nil
+}
+
+// blobSHA returns the git object ID of a blob with contents src.
+func blobSHA(src string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(src))
+	io.WriteString(h, src)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// updaters applies changes to each updater in turn.
+type updaters []Updater
+
+func (us updaters) Update(ctx context.Context, c FileChange) error {
+	for _, u := range us {
+		if err := u.Update(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updater returns the updater of the run: the changes are printed as diffs
+// if requested, then written to the rig and applied to Options.Updaters, or
+// only recorded in the plan of the run, if any. Nothing is written in dry-run
+// mode.
+func updater(opts Options) Updater {
+	var us updaters
+	if opts.Diff || opts.DryRun {
+		us = append(us, PatchUpdater{W: os.Stdout})
+	}
+	if opts.DryRun {
+		return us
+	}
+	if opts.Plan != nil {
+		return append(us, opts.Plan)
+	}
+	us = append(us, FsUpdater{Fs: opts.rig.Fs(), Root: opts.rig.Path})
+	us = append(us, opts.Updaters...)
+	return us
+}
+
+// flushUpdaters applies the changes batched by the updaters of the run.
+func flushUpdaters(ctx context.Context, opts Options) error {
+	if opts.DryRun || opts.Plan != nil {
+		return nil
+	}
+	for _, u := range opts.Updaters {
+		if f, ok := u.(Flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package bump
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v39/github"
+)
+
+func TestGithubUpdater(t *testing.T) {
+	var commits int
+	var tree struct {
+		BaseTree string             `json:"base_tree"`
+		Entries  []github.TreeEntry `json:"tree"`
+	}
+	var ref struct {
+		SHA   string `json:"sha"`
+		Force bool   `json:"force"`
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/repos/o/r/git/ref/heads/main", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(github.Reference{Ref: github.String("refs/heads/main"), Object: &github.GitObject{SHA: github.String("head")}})
+	})
+	mux.HandleFunc("GET /api/v3/repos/o/r/git/commits/head", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(github.Commit{SHA: github.String("head"), Tree: &github.Tree{SHA: github.String("base")}})
+	})
+	mux.HandleFunc("GET /api/v3/repos/o/r/git/trees/base", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(github.Tree{SHA: github.String("base"), Entries: []*github.TreeEntry{
+			{Path: github.String("Food/a.lua"), SHA: github.String(blobSHA("a 1.0.0"))},
+			{Path: github.String("Food/b.lua"), SHA: github.String(blobSHA("b 1.0.0"))},
+		}})
+	})
+	mux.HandleFunc("POST /api/v3/repos/o/r/git/trees", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&tree)
+		json.NewEncoder(w).Encode(github.Tree{SHA: github.String("new")})
+	})
+	mux.HandleFunc("POST /api/v3/repos/o/r/git/commits", func(w http.ResponseWriter, r *http.Request) {
+		commits++
+		json.NewEncoder(w).Encode(github.Commit{SHA: github.String("commit")})
+	})
+	mux.HandleFunc("PATCH /api/v3/repos/o/r/git/refs/heads/main", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&ref)
+		json.NewEncoder(w).Encode(github.Reference{Ref: github.String("refs/heads/main"), Object: &github.GitObject{SHA: github.String("commit")}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/api/v3/")
+	u := &GithubUpdater{Client: client, Org: "o", Repo: "r", Branch: "main"}
+
+	ctx := context.Background()
+	changes := []FileChange{
+		{Path: "Food/a.lua", Old: "a 1.0.0", New: "a 1.1.0", Mode: 0o644},
+		{Path: "Food/a.lua", Old: "a 1.1.0", New: "a 2.0.0", Mode: 0o644},
+		{Path: "Food/b.lua", Old: "b 1.0.0", New: "b 2.0.0", Mode: 0o644},
+		{Path: "Food/c.lua", New: "c 1.0.0", Mode: 0o644},
+	}
+	for _, c := range changes {
+		if err := u.Update(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := u.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if commits != 1 {
+		t.Errorf("made %d commits, want 1", commits)
+	}
+	if tree.BaseTree != "base" {
+		t.Errorf("got base tree %q, want base", tree.BaseTree)
+	}
+	want := map[string]string{"Food/a.lua": "a 2.0.0", "Food/b.lua": "b 2.0.0", "Food/c.lua": "c 1.0.0"}
+	if len(tree.Entries) != len(want) {
+		t.Errorf("got %d tree entries, want %d", len(tree.Entries), len(want))
+	}
+	for _, e := range tree.Entries {
+		if want[e.GetPath()] != e.GetContent() {
+			t.Errorf("got %s = %q, want %q", e.GetPath(), e.GetContent(), want[e.GetPath()])
+		}
+	}
+	if ref.SHA != "commit" || ref.Force {
+		t.Errorf("moved main to %q (force %v), want commit", ref.SHA, ref.Force)
+	}
+
+	// The branch has moved on since b.lua was read.
+	u.Update(ctx, FileChange{Path: "Food/b.lua", Old: "b 0.9.0", New: "b 2.0.0", Mode: 0o644})
+	if err := u.Flush(ctx); err == nil {
+		t.Error("committed a file changed on the branch")
+	}
+	if commits != 1 {
+		t.Errorf("made %d commits, want 1", commits)
+	}
+}
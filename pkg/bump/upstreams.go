@@ -0,0 +1,103 @@
+package bump
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+)
+
+// packageUpstream is the upstream of a package released apart from its food:
+// a GitHub release of another repository than the food.
+type packageUpstream struct {
+	Org  string
+	Repo string
+	// Version is the version of the package, as written in its URL.
+	Version string
+	// Latest is the latest release of the upstream, and Assets the files
+	// attached to it.
+	Latest source.Version
+	Assets []source.Asset
+}
+
+// newer returns the latest version of u if it is newer than the version of
+// the package.
+func (u packageUpstream) newer() (string, bool) {
+	version, err := semver.NewVersion(u.Version)
+	if err != nil {
+		return "", false
+	}
+	latest, err := semver.NewVersion(u.Latest.Tag)
+	if err != nil || !latest.GreaterThan(version) {
+		return "", false
+	}
+	return latest.String(), true
+}
+
+// ownUpstream returns the GitHub org and repo pkg of f is released from, and
+// its version, if pkg is released apart from f: it is downloaded from a
+// GitHub release of another repository than f, and its URL does not embed
+// the version of f.
+func ownUpstream(f gofish.Food, pkg *gofish.Package, opts Options) (string, string, string, bool) {
+	org, repo, tag := source.PackageRelease(pkg.URL)
+	if len(org) == 0 || len(versionIndexes(pkg.URL, f.Version)) > 0 {
+		return "", "", "", false
+	}
+	fOrg, fRepo := source.GithubRepo(f, opts.Release)
+	if strings.EqualFold(org+"/"+repo, fOrg+"/"+fRepo) {
+		return "", "", "", false
+	}
+	version := strings.TrimPrefix(tag, "v")
+	if len(versionIndexes(pkg.URL, version)) == 0 {
+		return "", "", "", false
+	}
+	return org, repo, version, true
+}
+
+// packageUpstreams resolves the latest releases of the packages of f that
+// are released apart from it, by package index. Each package is bumped
+// against its own upstream rather than the version of f.
+func packageUpstreams(ctx context.Context, f gofish.Food, opts Options) (map[int]packageUpstream, error) {
+	ups := map[int]packageUpstream{}
+	resolved := map[string]packageUpstream{}
+	for i, pkg := range f.Packages {
+		org, repo, version, ok := ownUpstream(f, pkg, opts)
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(org + "/" + repo + "@" + version)
+		u, ok := resolved[key]
+		if !ok {
+			u = packageUpstream{Org: org, Repo: repo, Version: version}
+			gh := &source.Github{Client: opts.GithubClient}
+			pkgFood := gofish.Food{Name: f.Name, Version: version, Packages: []*gofish.Package{pkg}}
+			var err error
+			u.Latest, u.Assets, err = gh.LatestVersion(ctx, pkgFood)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s package: %w", pkg.OS, pkg.Arch, err)
+			}
+			resolved[key] = u
+		}
+		ups[i] = u
+	}
+	return ups, nil
+}
+
+// addVersionChange adds c to changes, unless a change of the same version is
+// already there. Packages of different upstreams at the same version cannot
+// be bumped to different versions, as their URLs cannot be told apart.
+func addVersionChange(changes []versionChange, c versionChange) ([]versionChange, error) {
+	for _, e := range changes {
+		if e.Old != c.Old {
+			continue
+		}
+		if e.New != c.New {
+			return nil, fmt.Errorf("packages at %s bump to both %s and %s", c.Old, e.New, c.New)
+		}
+		return changes, nil
+	}
+	return append(changes, c), nil
+}
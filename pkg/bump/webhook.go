@@ -0,0 +1,90 @@
+package bump
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// SignatureHeader is the header of report webhook deliveries holding the
+// HMAC-SHA256 of their body, keyed by the webhook secret, as sha256=<hex>.
+const SignatureHeader = "X-Gfb-Signature-256"
+
+// webhookPayload is the body of report webhook deliveries.
+type webhookPayload struct {
+	Rig         string    `json:"rig"`
+	PullRequest string    `json:"pull_request,omitempty"`
+	Results     []Result  `json:"results"`
+	Findings    []Finding `json:"findings"`
+}
+
+// postReport posts the report of the run as JSON to opts.ReportWebhook,
+// signed with opts.ReportWebhookSecret if it is set. Failing to post is
+// logged, not returned, so it never fails the run.
+func postReport(ctx context.Context, r *Report, pullRequest string, opts Options) {
+	if len(opts.ReportWebhook) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	body, err := json.Marshal(webhookPayload{Rig: opts.Rig, PullRequest: pullRequest, Results: r.Results, Findings: r.Findings})
+	r.mu.Unlock()
+	if err == nil {
+		err = deliver(ctx, httpClient(opts), opts.ReportWebhook, body, opts.ReportWebhookSecret)
+	}
+	if err != nil {
+		slog.Warn("report webhook failed", "phase", "notify", "host", webhookHost(opts.ReportWebhook), "error", err)
+	}
+}
+
+// deliver posts the JSON body to hook, signing it with secret if it is set.
+func deliver(ctx context.Context, client *http.Client, hook string, body []byte, secret string) error {
+	header := http.Header{}
+	if len(secret) > 0 {
+		header.Set(SignatureHeader, Sign(body, secret))
+	}
+	return post(ctx, client, hook, body, header)
+}
+
+// post posts the JSON body to hook with header.
+func post(ctx context.Context, client *http.Client, hook string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// Slack and Discord webhook URLs hold their secret.
+		return urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("response code: %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of body sent in SignatureHeader, which
+// receivers compare to their own with hmac.Equal.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,104 @@
+package checksum
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// MissingPathsError is returned by Downloader when the archive of a package
+// does not hold the paths of some of its resources, such as when the
+// upstream moved them between releases.
+type MissingPathsError struct {
+	URL   string
+	Paths []string
+}
+
+func (e *MissingPathsError) Error() string {
+	return fmt.Sprintf("%s does not contain %s", e.URL, strings.Join(e.Paths, ", "))
+}
+
+// archiveFormat returns the format of the archive at rawURL, tar.gz or zip,
+// or an empty string if its contents cannot be inspected.
+func archiveFormat(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	name := strings.ToLower(path.Base(u.Path))
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// archivePaths returns the cleaned paths of the entries of f, an archive in
+// format.
+func archivePaths(f *os.File, format string) ([]string, error) {
+	var paths []string
+	switch format {
+	case "tar.gz":
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		tr := tar.NewReader(gz)
+		for {
+			h, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path.Clean(h.Name))
+		}
+	case "zip":
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		for _, zf := range zr.File {
+			paths = append(paths, path.Clean(zf.Name))
+		}
+	}
+	return paths, nil
+}
+
+// missingPaths returns the paths of want that are neither entries of the
+// archive nor directories holding some.
+func missingPaths(entries, want []string) []string {
+	var missing []string
+	for _, w := range want {
+		w = path.Clean(w)
+		found := false
+		for _, e := range entries {
+			if e == w || strings.HasPrefix(e, w+"/") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, w)
+		}
+	}
+	return missing
+}
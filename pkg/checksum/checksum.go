@@ -0,0 +1,73 @@
+// Package checksum computes and verifies the SHA-256 checksums of the
+// packages of foods.
+package checksum
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/arbourd/gfb/internal/transient"
+	"github.com/fishworks/gofish"
+)
+
+// Download downloads url and returns its SHA-256 checksum and size in bytes.
+func Download(ctx context.Context, client *http.Client, url string) (string, int64, error) {
+	return DownloadTo(ctx, client, url, io.Discard)
+}
+
+// DownloadTo downloads url to w and returns its SHA-256 checksum and size in
+// bytes.
+func DownloadTo(ctx context.Context, client *http.Client, url string, w io.Writer) (string, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("downloading package to calculate shasum: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", 0, transient.Error{Err: fmt.Errorf("downloading package %v\n\n"+"response code: %v\nresponse body: %v", url, resp.StatusCode, string(respBody))}
+	} else if resp.StatusCode >= 400 {
+		err := fmt.Errorf("downloading package %v\n\n"+"response code: %v", url, resp.StatusCode)
+		if transient.Status(resp.StatusCode) {
+			return "", 0, transient.Error{Err: err}
+		}
+		return "", 0, err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(h, w), resp.Body)
+	if err != nil {
+		return "", n, fmt.Errorf("downloading package: %v", err)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), n, nil
+}
+
+// Lint lints each package of food separately, returning every error. Linting
+// downloads each package, from its mirror if it has one, to verify its
+// checksum. Packages whose URLs are in skip, such as those with published
+// checksums, are not linted.
+func Lint(food gofish.Food, skip map[string]bool, mirrors []Mirror) []error {
+	var errs []error
+	for _, pkg := range food.Packages {
+		if skip[pkg.URL] {
+			continue
+		}
+		mirrored := *pkg
+		mirrored.URL = Rewrite(pkg.URL, mirrors)
+		single := food
+		single.Packages = []*gofish.Package{&mirrored}
+		for _, err := range single.Lint() {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", pkg.OS, pkg.Arch, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,45 @@
+package checksum
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mirror rewrites package URLs starting with From to start with To instead
+// when downloading them, so checksums can be computed from a mirror while
+// foods keep their canonical URLs. A trailing * on either is ignored, so
+// github.com/* → artifacts.corp.example/github/* is a prefix rule.
+type Mirror struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Validate returns an error if either side of the rule is empty.
+func (m Mirror) Validate() error {
+	if len(strings.TrimSuffix(m.From, "*")) == 0 || len(strings.TrimSuffix(m.To, "*")) == 0 {
+		return fmt.Errorf("mirror: empty rule: %s → %s", m.From, m.To)
+	}
+	return nil
+}
+
+// Rewrite returns the URL to download url from: url rewritten by the first
+// matching mirror, or url itself. Rules without a scheme match URLs of any
+// scheme, keeping it.
+func Rewrite(url string, mirrors []Mirror) string {
+	for _, m := range mirrors {
+		from := strings.TrimSuffix(m.From, "*")
+		to := strings.TrimSuffix(m.To, "*")
+
+		if strings.HasPrefix(url, from) {
+			return to + strings.TrimPrefix(url, from)
+		}
+		scheme, rest, ok := strings.Cut(url, "://")
+		if ok && !strings.Contains(from, "://") && strings.HasPrefix(rest, from) {
+			if !strings.Contains(to, "://") {
+				to = scheme + "://" + to
+			}
+			return to + strings.TrimPrefix(rest, from)
+		}
+	}
+	return url
+}
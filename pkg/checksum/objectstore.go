@@ -0,0 +1,132 @@
+package checksum
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcsTokenTTL is how long an access token of the gcloud CLI is reused.
+// Tokens are valid for an hour.
+const gcsTokenTTL = 45 * time.Minute
+
+// tokenTimeout bounds running the gcloud CLI for its access token.
+const tokenTimeout = 5 * time.Second
+
+var gcsToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Published returns the SHA-256 checksum the object store hosting the
+// package at u publishes for it, so large packages on S3 or GCS need not be
+// downloaded to compute it. It reads the checksum S3 stores for objects
+// uploaded with one, or the sha256 metadata of the object on S3 or GCS. It
+// returns false if the package is not on S3 or GCS, or no checksum is
+// published, and the package must be downloaded instead.
+//
+// Requests to GCS are authenticated with the access token of the gcloud CLI,
+// unless the transport of client sets its own credentials. Objects of
+// private S3 buckets are downloaded.
+func Published(ctx context.Context, client *http.Client, u string) (string, bool) {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "https" {
+		return "", false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	s3, gcs := isS3Host(host), isGCSHost(host)
+	if !s3 && !gcs {
+		return "", false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return "", false
+	}
+	if s3 {
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
+	}
+	if gcs {
+		if token := gcloudToken(ctx); len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Debug("reading object checksum failed", "url", u, "error", err)
+		return "", false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Debug("reading object checksum failed", "url", u, "status", resp.Status)
+		return "", false
+	}
+
+	// Checksums of objects uploaded in parts are checksums of the checksums
+	// of their parts, suffixed with the number of parts.
+	if sum := resp.Header.Get("x-amz-checksum-sha256"); len(sum) > 0 && resp.Header.Get("x-amz-checksum-type") != "COMPOSITE" && !strings.Contains(sum, "-") {
+		b, err := base64.StdEncoding.DecodeString(sum)
+		if err == nil && len(b) == 32 {
+			return hex.EncodeToString(b), true
+		}
+	}
+	for _, key := range []string{"x-amz-meta-sha256", "x-goog-meta-sha256"} {
+		sum := strings.ToLower(strings.TrimSpace(resp.Header.Get(key)))
+		if b, err := hex.DecodeString(sum); err == nil && len(b) == 32 {
+			return sum, true
+		}
+	}
+	return "", false
+}
+
+// isS3Host returns whether host is an S3 endpoint, addressing buckets by
+// path or by virtual host, in any region.
+func isS3Host(host string) bool {
+	if !strings.HasSuffix(host, ".amazonaws.com") {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, ".amazonaws.com"), ".") {
+		if label == "s3" || strings.HasPrefix(label, "s3-") {
+			return true
+		}
+	}
+	return false
+}
+
+// isGCSHost returns whether host is the GCS endpoint, addressing buckets by
+// path or by virtual host.
+func isGCSHost(host string) bool {
+	return host == "storage.googleapis.com" || strings.HasSuffix(host, ".storage.googleapis.com")
+}
+
+// gcloudToken returns the access token of the gcloud CLI, or an empty string
+// if it is not installed or not authenticated.
+func gcloudToken(ctx context.Context) string {
+	gcsToken.mu.Lock()
+	defer gcsToken.mu.Unlock()
+
+	if time.Now().Before(gcsToken.expires) {
+		return gcsToken.token
+	}
+	ctx, cancel := context.WithTimeout(ctx, tokenTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	cmd.Env = append(os.Environ(), "CLOUDSDK_CORE_DISABLE_PROMPTS=1")
+	out, err := cmd.Output()
+	if err != nil {
+		slog.Debug("no gcloud access token", "error", err)
+		out = nil
+	}
+	gcsToken.token = strings.TrimSpace(string(out))
+	gcsToken.expires = time.Now().Add(gcsTokenTTL)
+	return gcsToken.token
+}
@@ -0,0 +1,231 @@
+package checksum
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/arbourd/gfb/internal/transient"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/google/go-github/v39/github"
+)
+
+// Names of the built-in providers.
+const (
+	ProviderDownload      = "download"
+	ProviderChecksumsFile = "checksums-file"
+	ProviderGithubDigest  = "github-digest"
+	ProviderObjectStore   = "object-store"
+)
+
+// ErrUnavailable is returned by providers that cannot get the checksum of a
+// package, so the next provider is tried.
+var ErrUnavailable = errors.New("checksum unavailable")
+
+// Package is a package to get the checksum of.
+type Package struct {
+	// URL is the URL of the package as written in the food, before mirrors
+	// rewrite it.
+	URL string
+	// Assets are the files attached to the release of the package, if the
+	// source of the food knows them.
+	Assets []source.Asset
+	// Paths are the paths of the resources of the package inside its
+	// archive, checked by providers downloading it.
+	Paths []string
+}
+
+// Provider gets the SHA-256 checksums of packages.
+type Provider interface {
+	// Checksum returns the checksum of pkg and the number of bytes
+	// downloaded to get it. It returns ErrUnavailable if it cannot get the
+	// checksum of pkg.
+	Checksum(ctx context.Context, pkg Package) (string, int64, error)
+}
+
+// Downloader downloads packages to hash them. The tar.gz and zip archives of
+// packages with Paths are checked to hold them, failing with a
+// *MissingPathsError otherwise.
+type Downloader struct {
+	Client  *http.Client
+	Mirrors []Mirror
+}
+
+func (d Downloader) Checksum(ctx context.Context, pkg Package) (string, int64, error) {
+	format := archiveFormat(pkg.URL)
+	if len(pkg.Paths) == 0 || len(format) == 0 {
+		return Download(ctx, d.Client, Rewrite(pkg.URL, d.Mirrors))
+	}
+
+	tmp, err := os.CreateTemp("", "gfb-package-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	sha, n, err := DownloadTo(ctx, d.Client, Rewrite(pkg.URL, d.Mirrors), tmp)
+	if err != nil {
+		return sha, n, err
+	}
+	entries, err := archivePaths(tmp, format)
+	if err != nil {
+		return sha, n, fmt.Errorf("reading %s archive %s: %v", format, pkg.URL, err)
+	}
+	if missing := missingPaths(entries, pkg.Paths); len(missing) > 0 {
+		return sha, n, &MissingPathsError{URL: pkg.URL, Paths: missing}
+	}
+	return sha, n, nil
+}
+
+// ObjectStore reads the checksums S3 and GCS publish for packages hosted
+// there.
+type ObjectStore struct {
+	Client  *http.Client
+	Mirrors []Mirror
+}
+
+func (o ObjectStore) Checksum(ctx context.Context, pkg Package) (string, int64, error) {
+	sha, ok := Published(ctx, o.Client, Rewrite(pkg.URL, o.Mirrors))
+	if !ok {
+		return "", 0, ErrUnavailable
+	}
+	return sha, 0, nil
+}
+
+// checksumsFileRegex matches the names of checksums files attached to
+// releases, such as checksums.txt, SHA256SUMS or tool_1.2.3_checksums.txt.
+var checksumsFileRegex = regexp.MustCompile(`(?i)(checksums?|sha256sums?)(\.txt)?$`)
+
+// ChecksumsFile reads the checksums of packages from the checksums file
+// attached to their release, in the format of sha256sum or BSD.
+type ChecksumsFile struct {
+	Client  *http.Client
+	Mirrors []Mirror
+}
+
+func (c ChecksumsFile) Checksum(ctx context.Context, pkg Package) (string, int64, error) {
+	name := path.Base(pkg.URL)
+	var sums *source.Asset
+	for i, a := range pkg.Assets {
+		if a.Name == name+".sha256" || checksumsFileRegex.MatchString(a.Name) {
+			sums = &pkg.Assets[i]
+			break
+		}
+	}
+	if sums == nil {
+		return "", 0, ErrUnavailable
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, Rewrite(sums.URL, c.Mirrors), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("downloading checksums file: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("downloading checksums file %s\n\nresponse code: %v", sums.URL, resp.StatusCode)
+		if transient.Status(resp.StatusCode) {
+			return "", 0, transient.Error{Err: err}
+		}
+		return "", 0, err
+	}
+
+	counter := &countingReader{r: resp.Body}
+	sc := bufio.NewScanner(counter)
+	for sc.Scan() {
+		if sha, ok := parseChecksumLine(sc.Text(), name, sums.Name == name+".sha256"); ok {
+			return sha, counter.n, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", counter.n, fmt.Errorf("reading checksums file: %v", err)
+	}
+	return "", counter.n, ErrUnavailable
+}
+
+var bsdChecksumRegex = regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-fA-F]{64})$`)
+
+// parseChecksumLine returns the checksum of name in line, a line of a
+// sha256sum or BSD checksums file. A single checksum without a name is
+// accepted if single is set.
+func parseChecksumLine(line, name string, single bool) (string, bool) {
+	line = strings.TrimSpace(line)
+	if m := bsdChecksumRegex.FindStringSubmatch(line); m != nil {
+		return strings.ToLower(m[2]), m[1] == name
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields[0]) != 64 {
+		return "", false
+	}
+	if len(fields) == 1 {
+		return strings.ToLower(fields[0]), single
+	}
+	file := strings.TrimPrefix(fields[1], "*")
+	return strings.ToLower(fields[0]), file == name || path.Base(file) == name
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// GithubDigest reads the digests GitHub computes for release assets.
+type GithubDigest struct {
+	Client *github.Client
+}
+
+func (g GithubDigest) Checksum(ctx context.Context, pkg Package) (string, int64, error) {
+	u, err := url.Parse(pkg.URL)
+	if err != nil || u.Scheme != "https" || !strings.EqualFold(u.Host, "github.com") {
+		return "", 0, ErrUnavailable
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 6 || parts[2] != "releases" || parts[3] != "download" {
+		return "", 0, ErrUnavailable
+	}
+	org, repo, tag, name := parts[0], parts[1], parts[4], parts[5]
+
+	req, err := g.Client.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s/releases/tags/%s", org, repo, url.PathEscape(tag)), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	var release struct {
+		Assets []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"`
+		} `json:"assets"`
+	}
+	_, err = g.Client.Do(ctx, req, &release)
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return "", 0, ErrUnavailable
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("github release: %w", err)
+	}
+
+	for _, a := range release.Assets {
+		if sha, ok := strings.CutPrefix(a.Digest, "sha256:"); a.Name == name && ok {
+			return sha, 0, nil
+		}
+	}
+	return "", 0, ErrUnavailable
+}
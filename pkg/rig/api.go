@@ -0,0 +1,286 @@
+package rig
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/arbourd/gfb/internal/transient"
+	"github.com/google/go-github/v39/github"
+	"github.com/spf13/afero"
+)
+
+// remoteRig is the commit of a rig read through the GitHub API rather than
+// cloned, and the foods it had.
+type remoteRig struct {
+	org, name string
+	branch    string
+	commit    *github.Commit
+	// files maps the path of each food, relative to the root of the rig, to
+	// its contents at the commit.
+	files map[string]string
+}
+
+// downloadRig writes the foods of the rig branch to dir using the GitHub
+// Contents API, without cloning the rig.
+func downloadRig(ctx context.Context, dir string, opts Options) (*remoteRig, error) {
+	org, name, err := Repo(opts)
+	if err != nil {
+		return nil, err
+	}
+	r := &remoteRig{org: org, name: name, branch: opts.Branch, files: map[string]string{}}
+
+	if len(r.branch) == 0 {
+		repo, _, err := opts.GithubClient.Repositories.Get(ctx, org, name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrClone, err)
+		}
+		r.branch = repo.GetDefaultBranch()
+	}
+	branch, _, err := opts.GithubClient.Repositories.GetBranch(ctx, org, name, r.branch, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrClone, err)
+	}
+	r.commit = branch.GetCommit().GetCommit()
+	r.commit.SHA = branch.GetCommit().SHA
+
+	getOpts := &github.RepositoryContentGetOptions{Ref: r.commit.GetSHA()}
+	_, root, _, err := opts.GithubClient.Repositories.GetContents(ctx, org, name, "", getOpts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrClone, err)
+	}
+
+	for _, e := range root {
+		if e.GetType() == "dir" && e.GetPath() == hooksDir {
+			err := r.downloadHooks(ctx, dir, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, e := range root {
+		if e.GetType() == "file" && e.GetPath() == manifestFile {
+			return r, r.downloadManifest(ctx, dir, e.GetDownloadURL(), opts)
+		}
+	}
+	foodDir := detectFoodDir(opts, func(dir string) bool {
+		for _, e := range root {
+			if e.GetType() == "dir" && e.GetPath() == dir {
+				return true
+			}
+		}
+		return false
+	})
+
+	entries := root
+	if foodDir != "." {
+		_, entries, _, err = opts.GithubClient.Repositories.GetContents(ctx, org, name, foodDir, getOpts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrClone, err)
+		}
+	}
+	err = os.MkdirAll(filepath.Join(dir, filepath.FromSlash(foodDir)), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.GetType() != "file" || path.Ext(e.GetPath()) != ".lua" {
+			continue
+		}
+		// Raw downloads do not count against the API rate limit.
+		src, err := downloadRaw(ctx, e.GetDownloadURL(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrClone, e.GetPath(), err)
+		}
+		err = os.WriteFile(filepath.Join(dir, filepath.FromSlash(e.GetPath())), []byte(src), 0644)
+		if err != nil {
+			return nil, err
+		}
+		r.files[e.GetPath()] = src
+	}
+	slog.Debug("downloaded rig", "commit", r.commit.GetSHA(), "files", len(r.files))
+	return r, nil
+}
+
+// downloadManifest writes the manifest at url and the foods it lists to dir.
+func (r *remoteRig) downloadManifest(ctx context.Context, dir, url string, opts Options) error {
+	src, err := downloadRaw(ctx, url, opts)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrClone, manifestFile, err)
+	}
+	m, err := parseManifest([]byte(src))
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(dir, manifestFile), []byte(src), 0644)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range m.Foods {
+		u := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", r.org, r.name, r.commit.GetSHA(), p)
+		src, err := downloadRaw(ctx, u, opts)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrClone, p, err)
+		}
+		file := filepath.Join(dir, filepath.FromSlash(p))
+		err = os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(file, []byte(src), 0644)
+		if err != nil {
+			return err
+		}
+		r.files[p] = src
+	}
+	return nil
+}
+
+// downloadHooks writes the lua hooks of the rig to dir.
+func (r *remoteRig) downloadHooks(ctx context.Context, dir string, opts Options) error {
+	getOpts := &github.RepositoryContentGetOptions{Ref: r.commit.GetSHA()}
+	_, entries, _, err := opts.GithubClient.Repositories.GetContents(ctx, r.org, r.name, hooksDir, getOpts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrClone, err)
+	}
+	err = os.MkdirAll(filepath.Join(dir, hooksDir), 0755)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.GetType() != "file" || path.Ext(e.GetPath()) != ".lua" {
+			continue
+		}
+		src, err := downloadRaw(ctx, e.GetDownloadURL(), opts)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrClone, e.GetPath(), err)
+		}
+		err = os.WriteFile(filepath.Join(dir, filepath.FromSlash(e.GetPath())), []byte(src), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadRaw(ctx context.Context, url string, opts Options) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := opts.GithubClient.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("GET %s: %s", url, resp.Status)
+		if transient.Status(resp.StatusCode) {
+			return "", transient.Error{Err: err}
+		}
+		return "", err
+	}
+	b, err := io.ReadAll(resp.Body)
+	return string(b), err
+}
+
+// openPullRequestAPI commits the foods changed since the rig was downloaded
+// to a new branch using the Git Data API, and opens a pull request against
+// the rig branch. It returns the URL of the pull request, or an empty string
+// if there were no changes.
+func (r *Rig) openPullRequestAPI(ctx context.Context, title, body string) (string, error) {
+	remote, opts := r.remote, r.opts
+
+	// Foods are changed in place, and pinned foods are created beside them.
+	dirs := map[string]bool{}
+	if r.files == nil {
+		dirs[r.FoodPath] = true
+	}
+	for _, file := range r.files {
+		dirs[filepath.Dir(file)] = true
+	}
+
+	var entries []*github.TreeEntry
+	for dir := range dirs {
+		files, err := afero.ReadDir(r.fs, dir)
+		if err != nil {
+			return "", err
+		}
+		for _, file := range files {
+			if file.IsDir() || filepath.Ext(file.Name()) != ".lua" {
+				continue
+			}
+			b, err := afero.ReadFile(r.fs, filepath.Join(dir, file.Name()))
+			if err != nil {
+				return "", err
+			}
+			p := r.Rel(filepath.Join(dir, file.Name()))
+			if old, ok := remote.files[p]; ok && old == string(b) {
+				continue
+			}
+			entries = append(entries, &github.TreeEntry{
+				Path:    github.String(p),
+				Mode:    github.String("100644"),
+				Type:    github.String("blob"),
+				Content: github.String(string(b)),
+			})
+		}
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	now := time.Now()
+	author := &github.CommitAuthor{Name: github.String(opts.AuthorName), Email: github.String(opts.AuthorEmail), Date: &now}
+	commit, err := CommitTree(ctx, opts.GithubClient, remote.org, remote.name, remote.commit, entries, title+"\n\n"+body, author)
+	if err != nil {
+		return "", err
+	}
+
+	branch := "gfb/bump-" + now.UTC().Format("20060102-150405")
+	_, _, err = opts.GithubClient.Git.CreateRef(ctx, remote.org, remote.name, &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: commit.SHA},
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating branch %s: %w", branch, err)
+	}
+
+	host := githubRig{org: remote.org, name: remote.name, client: opts.GithubClient}
+	url, err := host.openPullRequest(ctx, branch, remote.branch, title, body)
+	if err != nil {
+		return "", fmt.Errorf("opening pull request: %w", err)
+	}
+
+	slog.Info("opened pull request", "url", url, "branch", branch)
+	return url, nil
+}
+
+// CommitTree commits entries on top of parent in org/name using the Git Data
+// API, and returns the commit. No branch is moved to it. The commit is
+// authored by the owner of the client if author is nil.
+func CommitTree(ctx context.Context, client *github.Client, org, name string, parent *github.Commit, entries []*github.TreeEntry, message string, author *github.CommitAuthor) (*github.Commit, error) {
+	tree, _, err := client.Git.CreateTree(ctx, org, name, parent.GetTree().GetSHA(), entries)
+	if err != nil {
+		return nil, fmt.Errorf("creating tree: %w", err)
+	}
+	commit, _, err := client.Git.CreateCommit(ctx, org, name, &github.Commit{
+		Message: github.String(message),
+		Tree:    tree,
+		Parents: []*github.Commit{{SHA: parent.SHA}},
+		Author:  author,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("committing: %w", err)
+	}
+	return commit, nil
+}
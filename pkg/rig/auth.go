@@ -0,0 +1,56 @@
+package rig
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// sshRigRegex matches the GitHub org and repo of rigs cloned over SSH, such as
+// git@github.com:fishworks/fish-food.git.
+var sshRigRegex = regexp.MustCompile(`^(?:ssh://)?git@github\.com[:/](?P<org>[\w-]+)/(?P<repo>[\w.-]+?)(?:\.git)?$`)
+
+// rigAuth returns the authentication used to clone and push to the rig at
+// url. SSH URLs authenticate with the SSH key if one is set, or else the SSH
+// agent. Other URLs authenticate with the rig token if one is set. The GitHub
+// token is only sent to github.com, never to other hosts.
+func rigAuth(url string, opts Options) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ep.Protocol {
+	case "ssh":
+		user := ep.User
+		if len(user) == 0 {
+			user = "git"
+		}
+		if len(opts.SSHKey) > 0 {
+			auth, err := gitssh.NewPublicKeysFromFile(user, opts.SSHKey, os.Getenv("GFB_SSH_KEY_PASSPHRASE"))
+			if err != nil {
+				return nil, fmt.Errorf("ssh key: %w", err)
+			}
+			return auth, nil
+		}
+		auth, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("ssh agent: %w", err)
+		}
+		return auth, nil
+	case "http", "https":
+		token := opts.Token
+		if len(token) == 0 && ep.Host == "github.com" {
+			token = opts.GithubToken
+		}
+		if len(token) == 0 {
+			return nil, nil
+		}
+		return &githttp.BasicAuth{Username: "gfb", Password: token}, nil
+	}
+	return nil, nil
+}
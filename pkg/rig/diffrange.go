@@ -0,0 +1,88 @@
+package rig
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// changedFiles returns the paths of the files of the checkout at root added
+// or modified in rng, a git range A..B or A...B. As with git diff, A...B
+// compares B with the merge base of A and B.
+func changedFiles(root, rng string) (map[string]bool, error) {
+	from, to, ok := strings.Cut(rng, "...")
+	mergeBase := ok
+	if !ok {
+		from, to, ok = strings.Cut(rng, "..")
+	}
+	if !ok {
+		return nil, fmt.Errorf("diff range %q is not of the form A..B or A...B", rng)
+	}
+	if len(from) == 0 {
+		from = "HEAD"
+	}
+	if len(to) == 0 {
+		to = "HEAD"
+	}
+
+	repo, err := git.PlainOpenWithOptions(root, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("diff range: %w", err)
+	}
+	fromCommit, err := resolveCommit(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := resolveCommit(repo, to)
+	if err != nil {
+		return nil, err
+	}
+	if mergeBase {
+		bases, err := fromCommit.MergeBase(toCommit)
+		if err != nil {
+			return nil, fmt.Errorf("diff range: merge base of %s and %s: %w", from, to, err)
+		}
+		if len(bases) == 0 {
+			return nil, fmt.Errorf("diff range: %s and %s have no merge base", from, to)
+		}
+		fromCommit = bases[0]
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := fromTree.Diff(toTree)
+	if err != nil {
+		return nil, fmt.Errorf("diff range: %w", err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]bool{}
+	for _, c := range changes {
+		if len(c.To.Name) == 0 {
+			continue
+		}
+		files[filepath.Join(wt.Filesystem.Root(), filepath.FromSlash(c.To.Name))] = true
+	}
+	return files, nil
+}
+
+func resolveCommit(repo *git.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("diff range: resolving %s: %w", rev, err)
+	}
+	return repo.CommitObject(*hash)
+}
@@ -0,0 +1,166 @@
+package rig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/barkimedes/go-deepcopy"
+	"github.com/fishworks/gofish"
+	"github.com/spf13/afero"
+	"github.com/yuin/gluamapper"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// readDir parses the foods in dir, or only those whose files are in only if
+// it is set.
+func readDir(fs afero.Fs, dir string, only map[string]bool) ([]gofish.Food, error) {
+	var feed []gofish.Food
+
+	files, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return feed, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".lua" {
+			continue
+		}
+
+		file := filepath.Join(dir, f.Name())
+		if only != nil && !only[file] {
+			continue
+		}
+		food, err := ParseFoodFile(fs, file)
+		if err != nil {
+			return feed, err
+		}
+		feed = append(feed, food)
+	}
+
+	return feed, nil
+}
+
+// ParseFoodFile evaluates the food file at path.
+func ParseFoodFile(fs afero.Fs, path string) (gofish.Food, error) {
+	b, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return gofish.Food{}, err
+	}
+	food, err := ParseFood(string(b))
+	if err != nil {
+		return food, fmt.Errorf("%s: %w", path, err)
+	}
+	return food, nil
+}
+
+// ParseFood evaluates the lua source of a food.
+func ParseFood(src string) (gofish.Food, error) {
+	L := lua.NewState()
+	defer L.Close()
+
+	var food gofish.Food
+	if err := L.DoString(src); err != nil {
+		return food, err
+	}
+	table, ok := L.GetGlobal("food").(*lua.LTable)
+	if !ok {
+		return food, fmt.Errorf("food is not defined")
+	}
+	if err := gluamapper.Map(table, &food); err != nil {
+		return food, err
+	}
+	return food, nil
+}
+
+// FoodFields evaluates the lua source of a food and returns the dotted paths
+// of the fields it sets, such as name or packages.resources.path, as written
+// in the source.
+func FoodFields(src string) ([]string, error) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(src); err != nil {
+		return nil, err
+	}
+	table, ok := L.GetGlobal("food").(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("food is not defined")
+	}
+	seen := map[string]bool{}
+	var fields []string
+	var walk func(prefix string, t *lua.LTable)
+	walk = func(prefix string, t *lua.LTable) {
+		t.ForEach(func(k, v lua.LValue) {
+			key, isString := k.(lua.LString)
+			if !isString {
+				// Entries of lists are fields of their list.
+				if vt, ok := v.(*lua.LTable); ok {
+					walk(prefix, vt)
+				}
+				return
+			}
+			field := prefix + string(key)
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+			if vt, ok := v.(*lua.LTable); ok {
+				walk(field+".", vt)
+			}
+		})
+	}
+	walk("", table)
+	return fields, nil
+}
+
+// CopyFood returns a deep copy of f.
+func CopyFood(f gofish.Food) (gofish.Food, error) {
+	f2, err := deepcopy.Anything(f)
+	if err != nil {
+		return f, err
+	}
+
+	return f2.(gofish.Food), nil
+}
+
+// ReadFoodFile returns the contents and file mode of foodFilePath.
+func ReadFoodFile(fs afero.Fs, foodFilePath string) (string, os.FileMode, error) {
+	info, err := fs.Stat(foodFilePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("finding info of file %s: %w", foodFilePath, err)
+	}
+
+	foodBytes, err := afero.ReadFile(fs, foodFilePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("reading file %s: %w", foodFilePath, err)
+	}
+	return string(foodBytes), info.Mode(), nil
+}
+
+// WriteFoodFile replaces the contents of foodFilePath with src, creating it
+// if needed. The food is written to a temporary file beside it and renamed
+// over the original, so it is never left partially written.
+func WriteFoodFile(fs afero.Fs, foodFilePath, src string, mode os.FileMode) error {
+	dir, base := filepath.Split(foodFilePath)
+	tmp, err := afero.TempFile(fs, dir, "."+base+".")
+	if err != nil {
+		return fmt.Errorf("writing to file %s: %w", foodFilePath, err)
+	}
+	defer fs.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fs.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		err = fs.Rename(tmp.Name(), foodFilePath)
+	}
+	if err != nil {
+		return fmt.Errorf("writing to file %s: %w", foodFilePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package rig
+
+import (
+	"path"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/afero"
+)
+
+// foodDirs are the directories foods are looked for in, in order, when the
+// food directory is not set. Rigs without any of them keep foods at their
+// root.
+var foodDirs = []string{"Food", "food", "foods"}
+
+// detectFoodDir returns the directory of the rig holding its foods, relative
+// to the root of the rig. exists reports whether a directory of the rig
+// exists.
+func detectFoodDir(opts Options, exists func(dir string) bool) string {
+	if len(opts.FoodDir) > 0 {
+		return opts.FoodDir
+	}
+	for _, d := range foodDirs {
+		if exists(d) {
+			return d
+		}
+	}
+	return "."
+}
+
+// dirExists returns a function reporting whether a directory exists under
+// root.
+func dirExists(fs afero.Fs, root string) func(string) bool {
+	return func(dir string) bool {
+		ok, err := afero.DirExists(fs, filepath.Join(root, filepath.FromSlash(dir)))
+		return err == nil && ok
+	}
+}
+
+// sparseDirs returns the paths of the rig at commit hash of repo to check
+// out: its manifest and the directories of the foods it lists, or else its
+// food directory. It returns nil to check out the whole rig when foods are
+// kept at its root.
+func sparseDirs(repo *git.Repository, hash plumbing.Hash, opts Options) ([]string, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	if f, err := tree.File(manifestFile); err == nil {
+		src, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+		m, err := parseManifest([]byte(src))
+		if err != nil {
+			return nil, err
+		}
+		// Directories are checked out whole: go-git skips a directory
+		// if the first file in it is skipped.
+		dirs := append([]string{manifestFile}, hookDirs(tree)...)
+		seen := map[string]bool{}
+		for _, p := range m.Foods {
+			dir := path.Dir(p)
+			if dir == "." {
+				return nil, nil
+			}
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+		return dirs, nil
+	}
+
+	foodDir := detectFoodDir(opts, func(dir string) bool {
+		_, err := tree.Tree(dir)
+		return err == nil
+	})
+	if foodDir == "." {
+		return nil, nil
+	}
+	return append([]string{foodDir}, hookDirs(tree)...), nil
+}
+
+// hookDirs returns the hooks directory of the rig in a list, if it has one.
+func hookDirs(tree *object.Tree) []string {
+	if _, err := tree.Tree(hooksDir); err != nil {
+		return nil
+	}
+	return []string{hooksDir}
+}
+
+// IsFood reports whether p, a slash-separated path relative to the root of
+// the rig, is a food: a file in the food directory, or beside a food listed
+// by the manifest.
+func (r *Rig) IsFood(p string) bool {
+	if r.files == nil {
+		return InFoodDir(p, r.FoodDir)
+	}
+	if path.Ext(p) != ".lua" {
+		return false
+	}
+	for _, file := range r.files {
+		if r.Rel(filepath.Dir(file)) == path.Dir(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// InFoodDir reports whether p, a slash-separated path relative to the root of
+// a rig, is a food in foodDir, or in any of the directories foods are looked
+// for in if foodDir is empty.
+func InFoodDir(p, foodDir string) bool {
+	if path.Ext(p) != ".lua" {
+		return false
+	}
+	dir := path.Dir(p)
+	if len(foodDir) > 0 {
+		return dir == foodDir
+	}
+	for _, d := range append(foodDirs, ".") {
+		if dir == d {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package rig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fishworks/gofish"
+	"github.com/spf13/afero"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// hooksDir is the optional directory at the root of a rig holding lua hooks
+// run around bumps.
+const hooksDir = "hooks"
+
+// preBumpHook is the file of the hook run before each food is bumped. It
+// defines a function pre_bump(old, new) called with the food and the food it
+// is about to be bumped to. Returning false, optionally with a reason, vetoes
+// the bump. The description and homepage of new may be changed.
+const preBumpHook = "pre_bump.lua"
+
+// PreBump runs the pre_bump hook of the rig, if it has one, with old and
+// new. It returns the reason the hook vetoed the bump, or an empty string.
+// Changes the hook makes to the description and homepage of new are kept.
+func (r *Rig) PreBump(old gofish.Food, new *gofish.Food) (string, error) {
+	file := filepath.Join(r.Path, hooksDir, preBumpHook)
+	src, err := afero.ReadFile(r.fs, file)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	L := lua.NewState()
+	defer L.Close()
+	if err := L.DoString(string(src)); err != nil {
+		return "", fmt.Errorf("%s: %w", r.Rel(file), err)
+	}
+	fn, ok := L.GetGlobal("pre_bump").(*lua.LFunction)
+	if !ok {
+		return "", fmt.Errorf("%s: pre_bump is not defined", r.Rel(file))
+	}
+
+	newTable := foodTable(L, *new)
+	err = L.CallByParam(lua.P{Fn: fn, NRet: 2, Protect: true}, foodTable(L, old), newTable)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", r.Rel(file), err)
+	}
+	allow, reason := L.Get(-2), L.Get(-1)
+	L.Pop(2)
+	if allow == lua.LFalse {
+		if s, isString := reason.(lua.LString); isString && len(s) > 0 {
+			return string(s), nil
+		}
+		return "vetoed by " + r.Rel(file), nil
+	}
+
+	if s, isString := newTable.RawGetString("description").(lua.LString); isString {
+		new.Description = string(s)
+	}
+	if s, isString := newTable.RawGetString("homepage").(lua.LString); isString {
+		new.Homepage = string(s)
+	}
+	return "", nil
+}
+
+// foodTable returns f as a lua table, keyed as in food files.
+func foodTable(L *lua.LState, f gofish.Food) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("name", lua.LString(f.Name))
+	t.RawSetString("description", lua.LString(f.Description))
+	t.RawSetString("license", lua.LString(f.License))
+	t.RawSetString("homepage", lua.LString(f.Homepage))
+	t.RawSetString("caveats", lua.LString(f.Caveats))
+	t.RawSetString("version", lua.LString(f.Version))
+
+	packages := L.NewTable()
+	for _, pkg := range f.Packages {
+		p := L.NewTable()
+		p.RawSetString("os", lua.LString(pkg.OS))
+		p.RawSetString("arch", lua.LString(pkg.Arch))
+		p.RawSetString("url", lua.LString(pkg.URL))
+		p.RawSetString("sha256", lua.LString(pkg.SHA256))
+		mirrors := L.NewTable()
+		for _, m := range pkg.Mirrors {
+			mirrors.Append(lua.LString(m))
+		}
+		p.RawSetString("mirrors", mirrors)
+		resources := L.NewTable()
+		for _, res := range pkg.Resources {
+			r := L.NewTable()
+			r.RawSetString("path", lua.LString(res.Path))
+			r.RawSetString("installpath", lua.LString(res.InstallPath))
+			r.RawSetString("executable", lua.LBool(res.Executable))
+			resources.Append(r)
+		}
+		p.RawSetString("resources", resources)
+		packages.Append(p)
+	}
+	t.RawSetString("packages", packages)
+	return t
+}
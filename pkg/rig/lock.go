@@ -0,0 +1,115 @@
+package rig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v39/github"
+)
+
+// lockRef is the ref of the rig held by the run that is changing it.
+const lockRef = "refs/gfb/lock"
+
+// ErrLocked is wrapped by errors taking the lock held by another run.
+var ErrLocked = errors.New("rig is locked by another run")
+
+// Lock takes the lease on the rig for holder for ttl, so overlapping runs
+// cannot open duplicate or conflicting pull requests. The lease is an
+// annotated tag on the head of the rig, referenced by lockRef, naming its
+// holder and when it expires. An expired lease is broken. The returned
+// function releases the lease. Rigs not hosted on GitHub are not locked.
+func Lock(ctx context.Context, opts Options, holder string, ttl time.Duration) (func(), error) {
+	org, name, err := Repo(opts)
+	if err != nil {
+		slog.Warn("not locking rig", "reason", err)
+		return func() {}, nil
+	}
+
+	ref, resp, err := opts.GithubClient.Git.GetRef(ctx, org, name, lockRef)
+	switch {
+	case err == nil:
+		holder, expires, err := readLease(ctx, org, name, ref, opts)
+		if err != nil {
+			return nil, err
+		}
+		if time.Now().Before(expires) {
+			return nil, fmt.Errorf("%w: held by %s until %s", ErrLocked, holder, expires.Format(time.RFC3339))
+		}
+		slog.Warn("breaking expired lock", "holder", holder, "expired", expires.Format(time.RFC3339))
+		_, err = opts.GithubClient.Git.DeleteRef(ctx, org, name, lockRef)
+		if err != nil {
+			return nil, fmt.Errorf("breaking lock: %w", err)
+		}
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+	default:
+		return nil, fmt.Errorf("reading lock: %w", err)
+	}
+
+	repo, _, err := opts.GithubClient.Repositories.Get(ctx, org, name)
+	if err != nil {
+		return nil, err
+	}
+	head, _, err := opts.GithubClient.Git.GetRef(ctx, org, name, "refs/heads/"+repo.GetDefaultBranch())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tag, _, err := opts.GithubClient.Git.CreateTag(ctx, org, name, &github.Tag{
+		Tag:     github.String("gfb-lock"),
+		Message: github.String(fmt.Sprintf("holder: %s\nexpires: %s\n", holder, now.Add(ttl).Format(time.RFC3339))),
+		Object:  &github.GitObject{Type: github.String("commit"), SHA: head.GetObject().SHA},
+		Tagger:  &github.CommitAuthor{Name: github.String(opts.AuthorName), Email: github.String(opts.AuthorEmail), Date: &now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating lock: %w", err)
+	}
+	_, resp, err = opts.GithubClient.Git.CreateRef(ctx, org, name, &github.Reference{
+		Ref:    github.String(lockRef),
+		Object: &github.GitObject{SHA: tag.SHA},
+	})
+	if resp != nil && resp.StatusCode == http.StatusUnprocessableEntity {
+		return nil, fmt.Errorf("%w: lock was taken concurrently", ErrLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating lock: %w", err)
+	}
+	slog.Debug("took lock", "holder", holder, "ttl", ttl.String())
+
+	return func() {
+		// The run's context may be done, but the lease must still be released.
+		_, err := opts.GithubClient.Git.DeleteRef(context.Background(), org, name, lockRef)
+		if err != nil {
+			slog.Error("releasing lock failed", "error", err)
+		}
+	}, nil
+}
+
+// readLease returns the holder and expiry of the lease referenced by ref.
+func readLease(ctx context.Context, org, name string, ref *github.Reference, opts Options) (string, time.Time, error) {
+	tag, _, err := opts.GithubClient.Git.GetTag(ctx, org, name, ref.GetObject().GetSHA())
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("reading lock: %w", err)
+	}
+
+	var holder string
+	var expires time.Time
+	for _, line := range strings.Split(tag.GetMessage(), "\n") {
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "holder":
+			holder = value
+		case "expires":
+			expires, err = time.Parse(time.RFC3339, value)
+			if err != nil {
+				return "", time.Time{}, fmt.Errorf("reading lock: %w", err)
+			}
+		}
+	}
+	return holder, expires, nil
+}
@@ -0,0 +1,96 @@
+package rig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/fishworks/gofish"
+	"github.com/spf13/afero"
+)
+
+// manifestFile is the optional manifest at the root of a rig. When present,
+// only the files it lists are foods, and the rig's directories are not
+// scanned.
+const manifestFile = "foods.json"
+
+// Manifest enumerates the foods of a rig.
+type Manifest struct {
+	// Foods are the slash-separated paths of the foods, relative to the root
+	// of the rig.
+	Foods []string `json:"foods"`
+}
+
+// parseManifest parses the manifest b, rejecting paths outside the rig.
+func parseManifest(b []byte) (*Manifest, error) {
+	m := &Manifest{}
+	err := json.Unmarshal(b, m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	for _, p := range m.Foods {
+		if !fs.ValidPath(p) || path.Ext(p) != ".lua" {
+			return nil, fmt.Errorf("%s: invalid food path: %s", manifestFile, p)
+		}
+	}
+	return m, nil
+}
+
+// readManifest reads the manifest of the rig at root. It returns nil if the
+// rig has no manifest.
+func readManifest(fsys afero.Fs, root string) (*Manifest, error) {
+	b, err := afero.ReadFile(fsys, filepath.Join(root, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(b)
+}
+
+// loadFoods parses the foods of the rig at root, listed in its manifest or
+// else found in its food directory.
+func (r *Rig) loadFoods(root string) ([]gofish.Food, error) {
+	r.Path = root
+	r.FoodDir = detectFoodDir(r.opts, dirExists(r.fs, root))
+	r.FoodPath = filepath.Join(root, filepath.FromSlash(r.FoodDir))
+	r.files = nil
+
+	var changed map[string]bool
+	if len(r.opts.DiffRange) > 0 {
+		var err error
+		changed, err = changedFiles(root, r.opts.DiffRange)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := readManifest(r.fs, root)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return readDir(r.fs, r.FoodPath, changed)
+	}
+
+	r.files = map[string]string{}
+	var feed []gofish.Food
+	for _, p := range m.Foods {
+		file := filepath.Join(root, filepath.FromSlash(p))
+		if changed != nil && !changed[file] {
+			continue
+		}
+		f, err := ParseFoodFile(r.fs, file)
+		if err != nil {
+			return nil, err
+		}
+		r.files[f.Name] = file
+		feed = append(feed, f)
+	}
+	return feed, nil
+}
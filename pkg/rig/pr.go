@@ -0,0 +1,98 @@
+package rig
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// OpenPullRequest commits the changes in the rig's working tree to a new
+// branch, pushes it and opens a pull request with title and body against the
+// branch the rig was cloned from. It returns the URL of the pull request, or
+// an empty string if there were no changes.
+func (r *Rig) OpenPullRequest(ctx context.Context, title, body string) (string, error) {
+	if r.remote != nil {
+		return r.openPullRequestAPI(ctx, title, body)
+	}
+	opts := r.opts
+
+	host, err := newRigHost(opts)
+	if err != nil {
+		return "", fmt.Errorf("opening pull request: %w", err)
+	}
+
+	repo, err := git.PlainOpen(r.Path)
+	if err != nil {
+		return "", err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return "", err
+	}
+	// Only foods are changed by a run. Files outside the food directory are
+	// missing from the sparse checkout, and would be reported as deleted.
+	var paths []string
+	for path, s := range status {
+		if r.IsFood(path) && s.Worktree != git.Unmodified {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	base := head.Name().Short()
+
+	branch := plumbing.NewBranchReferenceName("gfb/bump-" + time.Now().UTC().Format("20060102-150405"))
+	err = wt.Checkout(&git.CheckoutOptions{Branch: branch, Create: true, Keep: true})
+	if err != nil {
+		return "", fmt.Errorf("creating branch: %w", err)
+	}
+	for _, path := range paths {
+		if _, err := wt.Add(path); err != nil {
+			return "", fmt.Errorf("adding %s: %w", path, err)
+		}
+	}
+
+	_, err = wt.Commit(title+"\n\n"+body, &git.CommitOptions{
+		Author: &object.Signature{Name: opts.AuthorName, Email: opts.AuthorEmail, When: time.Now()},
+	})
+	if err != nil {
+		return "", fmt.Errorf("committing: %w", err)
+	}
+
+	auth, err := rigAuth(URL(opts), opts)
+	if err != nil {
+		return "", err
+	}
+	err = repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec(branch + ":" + branch)},
+		Auth:       auth,
+	})
+	if err != nil {
+		return "", fmt.Errorf("pushing %s: %w", branch.Short(), err)
+	}
+
+	url, err := host.openPullRequest(ctx, branch.Short(), base, title, body)
+	if err != nil {
+		return "", fmt.Errorf("opening pull request: %w", err)
+	}
+
+	slog.Info("opened pull request", "url", url, "branch", branch.Short())
+	return url, nil
+}
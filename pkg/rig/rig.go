@@ -0,0 +1,281 @@
+// Package rig reads the foods of a fish food rig from a clone, a local
+// checkout or the GitHub API, and writes changes to them back as pull
+// requests.
+package rig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/arbourd/gfb/internal/trace"
+	"github.com/arbourd/gfb/pkg/source"
+	"github.com/fishworks/gofish"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-github/v39/github"
+	"github.com/spf13/afero"
+)
+
+// ErrClone is wrapped by errors cloning the rig.
+var ErrClone = errors.New("cloning rig")
+
+// Options configures how the rig is read and changed.
+type Options struct {
+	// URL is the URL of the rig to clone, or the path of a local checkout of
+	// it to change in place.
+	URL string
+	// Branch is the branch of the rig to clone and open pull requests
+	// against, instead of its default branch.
+	Branch string
+	// Host is the kind of forge hosting the rig: github, gitlab or gitea.
+	// It is detected from the rig URL if empty.
+	Host string
+	// SSHKey is the path of the private key authenticating to rigs cloned
+	// over SSH. The SSH agent is used if it is empty.
+	SSHKey string
+	// Token authenticates cloning and pushing to rigs over HTTPS, if it
+	// differs from the GitHub token.
+	Token       string
+	GithubToken string
+	// API reads the rig and commits changes to it through the GitHub API,
+	// instead of cloning it with git.
+	API bool
+	// FoodDir is the directory of the rig holding its foods, relative to its
+	// root. It is detected when the rig is loaded if empty.
+	FoodDir string
+	// Workdir is where the rig is cloned and kept between runs, if set.
+	Workdir string
+	// DiffRange limits the foods loaded to those added or modified in a git
+	// range of a local checkout of the rig, such as origin/main...HEAD.
+	DiffRange string
+
+	AuthorName  string
+	AuthorEmail string
+
+	GithubClient *github.Client
+	HTTPClient   *http.Client
+	// Fs holds local checkouts of the rig. Clones of the rig are always made
+	// on the OS filesystem. The OS filesystem is used if it is nil.
+	Fs afero.Fs
+}
+
+func (o Options) httpClient() *http.Client {
+	if o.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return o.HTTPClient
+}
+
+func (o Options) fs() afero.Fs {
+	if o.Fs == nil {
+		return afero.NewOsFs()
+	}
+	return o.Fs
+}
+
+// Rig is a loaded rig: a checkout of it, or the foods of a commit of it
+// downloaded through the GitHub API.
+type Rig struct {
+	// Path is the root of the checkout.
+	Path string
+	// FoodDir is the directory holding the foods, relative to Path, and
+	// FoodPath its path.
+	FoodDir  string
+	FoodPath string
+
+	opts Options
+	fs   afero.Fs
+	// files maps foods to their files, when listed by a rig manifest.
+	files  map[string]string
+	remote *remoteRig
+}
+
+// Local reports whether the rig is the path of a local checkout rather than
+// a URL.
+func Local(opts Options) bool {
+	ok, err := afero.DirExists(opts.fs(), opts.URL)
+	return err == nil && ok
+}
+
+// URL returns the URL of the rig: the rig itself, or the URL of the origin
+// remote of a local checkout.
+func URL(opts Options) string {
+	if !Local(opts) {
+		return opts.URL
+	}
+	repo, err := git.PlainOpen(opts.URL)
+	if err != nil {
+		return opts.URL
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return opts.URL
+	}
+	return remote.Config().URLs[0]
+}
+
+// Repo returns the GitHub org and repo of the rig.
+func Repo(opts Options) (string, string, error) {
+	url := URL(opts)
+	if m := sshRigRegex.FindStringSubmatch(url); m != nil {
+		return m[1], m[2], nil
+	}
+	results := source.GithubRegex.FindAllStringSubmatch(url, -1)
+	if len(results) == 0 {
+		return "", "", fmt.Errorf("rig is not hosted on github: %s", url)
+	}
+	return results[0][1], results[0][2], nil
+}
+
+// Load clones the rig into a temporary directory and parses its foods. The
+// returned function removes the clone. A local checkout is used in place, and
+// its changes are left for the user to review. In API mode, only the foods
+// are downloaded.
+func Load(ctx context.Context, opts Options) (*Rig, []gofish.Food, func(), error) {
+	r := &Rig{opts: opts, fs: afero.NewOsFs()}
+	loadFoods := func(dir string) ([]gofish.Food, error) {
+		_, span := trace.Start(ctx, "parse foods")
+		feed, err := r.loadFoods(dir)
+		span.SetAttr("foods", strconv.Itoa(len(feed)))
+		span.End(err)
+		return feed, err
+	}
+
+	if Local(opts) {
+		r.fs = opts.fs()
+		dir, err := filepath.Abs(opts.URL)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		feed, err := loadFoods(dir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return r, feed, func() {}, nil
+	}
+	if len(opts.DiffRange) > 0 {
+		return nil, nil, nil, fmt.Errorf("diff range %s needs a local checkout of the rig", opts.DiffRange)
+	}
+
+	if len(opts.Workdir) > 0 && !opts.API {
+		cloneCtx, span := trace.Start(ctx, "clone rig", "rig", opts.URL)
+		err := syncWorkdir(cloneCtx, opts)
+		span.End(err)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		feed, err := loadFoods(opts.Workdir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return r, feed, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "gfb_")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	cloneCtx, span := trace.Start(ctx, "clone rig", "rig", opts.URL)
+	if opts.API {
+		r.remote, err = downloadRig(cloneCtx, dir, opts)
+	} else {
+		err = cloneRig(cloneCtx, dir, opts)
+	}
+	span.End(err)
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, err
+	}
+	feed, err := loadFoods(dir)
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, err
+	}
+	return r, feed, cleanup, nil
+}
+
+// cloneRig makes a shallow clone of the rig in dir, checking out only the
+// food directory. Nothing else in the rig is read.
+func cloneRig(ctx context.Context, dir string, opts Options) error {
+	auth, err := rigAuth(opts.URL, opts)
+	if err != nil {
+		return err
+	}
+	cloneOpts := &git.CloneOptions{
+		URL:        opts.URL,
+		Auth:       auth,
+		Depth:      1,
+		NoCheckout: true,
+	}
+	if len(opts.Branch) > 0 {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(opts.Branch)
+		cloneOpts.SingleBranch = true
+	}
+	repo, err := git.PlainCloneContext(ctx, dir, false, cloneOpts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrClone, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrClone, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	dirs, err := sparseDirs(repo, head.Hash(), opts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrClone, err)
+	}
+	err = wt.Checkout(&git.CheckoutOptions{Branch: head.Name(), SparseCheckoutDirectories: dirs})
+	if err != nil {
+		return fmt.Errorf("%w: checking out %s: %w", ErrClone, head.Name().Short(), err)
+	}
+	return nil
+}
+
+// Fs returns the filesystem holding the rig.
+func (r *Rig) Fs() afero.Fs {
+	return r.fs
+}
+
+// FoodFile returns the path of the file of the named food.
+func (r *Rig) FoodFile(name string) string {
+	if file, ok := r.files[name]; ok {
+		return file
+	}
+	return filepath.Join(r.FoodPath, name+".lua")
+}
+
+// Rel returns the slash-separated path of file relative to the root of the
+// rig, or file itself if it is not in the rig.
+func (r *Rig) Rel(file string) string {
+	if rel, err := filepath.Rel(r.Path, file); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(file)
+}
+
+// Head returns the SHA of the commit of the rig that was loaded.
+func (r *Rig) Head() (string, error) {
+	if r.remote != nil {
+		return r.remote.commit.GetSHA(), nil
+	}
+	repo, err := git.PlainOpenWithOptions(r.Path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
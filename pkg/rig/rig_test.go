@@ -0,0 +1,34 @@
+package rig
+
+import "testing"
+
+func TestRepo(t *testing.T) {
+	tests := []struct {
+		url       string
+		org, repo string
+	}{
+		{"https://github.com/fishworks/fish-food", "fishworks", "fish-food"},
+		{"https://github.com/fishworks/fish-food.git", "fishworks", "fish-food"},
+		{"https://github.com/arbourd/food.rig.git", "arbourd", "food.rig"},
+		{"git@github.com:fishworks/fish-food.git", "fishworks", "fish-food"},
+		{"git@github.com:fishworks/fish-food", "fishworks", "fish-food"},
+		{"git@github.com:arbourd/food.rig.git", "arbourd", "food.rig"},
+		{"git@github.com:arbourd/food.rig", "arbourd", "food.rig"},
+		{"ssh://git@github.com/arbourd/food.rig.git", "arbourd", "food.rig"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			org, repo, err := Repo(Options{URL: tt.url})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if org != tt.org || repo != tt.repo {
+				t.Errorf("got %q/%q, want %q/%q", org, repo, tt.org, tt.repo)
+			}
+		})
+	}
+
+	if _, _, err := Repo(Options{URL: "git@gitlab.com:fishworks/fish-food.git"}); err == nil {
+		t.Error("got no error for a rig not on GitHub")
+	}
+}
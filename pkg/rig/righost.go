@@ -0,0 +1,169 @@
+package rig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/arbourd/gfb/internal/transient"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/v39/github"
+)
+
+const (
+	RigHostGithub = "github"
+	RigHostGitlab = "gitlab"
+	RigHostGitea  = "gitea"
+)
+
+// rigHost is the forge hosting the rig, on which pull requests are opened.
+type rigHost interface {
+	// openPullRequest opens a pull request merging head into base, and
+	// returns its URL.
+	openPullRequest(ctx context.Context, head, base, title, body string) (string, error)
+}
+
+// newRigHost returns the forge hosting the rig. The kind of forge is
+// detected from the rig URL unless set in the options.
+func newRigHost(opts Options) (rigHost, error) {
+	u := URL(opts)
+	ep, err := transport.NewEndpoint(u)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.TrimSuffix(strings.Trim(ep.Path, "/"), ".git")
+
+	kind := opts.Host
+	if len(kind) == 0 {
+		switch {
+		case ep.Host == "github.com":
+			kind = RigHostGithub
+		case strings.Contains(ep.Host, "gitlab"):
+			kind = RigHostGitlab
+		case strings.Contains(ep.Host, "gitea"):
+			kind = RigHostGitea
+		default:
+			return nil, fmt.Errorf("cannot detect the host of rig %s: set -rig-host", u)
+		}
+	}
+
+	// Rigs cloned over SSH are reached over HTTPS on the same host.
+	base := "https://" + ep.Host
+	if ep.Protocol == "http" || ep.Protocol == "https" {
+		base = ep.Protocol + "://" + ep.Host
+		if ep.Port != 0 {
+			base += fmt.Sprintf(":%d", ep.Port)
+		}
+	}
+
+	// The GitHub token is never sent to other forges.
+	if kind != RigHostGithub && len(opts.Token) == 0 {
+		return nil, fmt.Errorf("opening pull requests on %s requires -rig-token", ep.Host)
+	}
+
+	switch kind {
+	case RigHostGithub:
+		org, name, err := Repo(opts)
+		if err != nil {
+			return nil, err
+		}
+		return githubRig{org: org, name: name, client: opts.GithubClient}, nil
+	case RigHostGitlab:
+		return gitlabRig{base: base, project: path, token: opts.Token, client: opts.httpClient()}, nil
+	case RigHostGitea:
+		return giteaRig{base: base, repo: path, token: opts.Token, client: opts.httpClient()}, nil
+	}
+	return nil, fmt.Errorf("unknown rig host: %s", kind)
+}
+
+type githubRig struct {
+	org, name string
+	client    *github.Client
+}
+
+func (r githubRig) openPullRequest(ctx context.Context, head, base, title, body string) (string, error) {
+	pr, _, err := r.client.PullRequests.Create(ctx, r.org, r.name, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(head),
+		Base:  github.String(base),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return "", err
+	}
+	return pr.GetHTMLURL(), nil
+}
+
+// gitlabRig is a rig hosted on GitLab, on which merge requests are opened.
+type gitlabRig struct {
+	base    string
+	project string
+	token   string
+	client  *http.Client
+}
+
+func (r gitlabRig) openPullRequest(ctx context.Context, head, base, title, body string) (string, error) {
+	var mr struct {
+		WebURL string `json:"web_url"`
+	}
+	err := postJSON(ctx, r.client, fmt.Sprintf("%s/api/v4/projects/%s/merge_requests", r.base, url.PathEscape(r.project)),
+		map[string]string{"PRIVATE-TOKEN": r.token},
+		map[string]string{"source_branch": head, "target_branch": base, "title": title, "description": body},
+		&mr)
+	return mr.WebURL, err
+}
+
+// giteaRig is a rig hosted on Gitea or Forgejo.
+type giteaRig struct {
+	base   string
+	repo   string
+	token  string
+	client *http.Client
+}
+
+func (r giteaRig) openPullRequest(ctx context.Context, head, base, title, body string) (string, error) {
+	var pr struct {
+		HTMLURL string `json:"html_url"`
+	}
+	err := postJSON(ctx, r.client, fmt.Sprintf("%s/api/v1/repos/%s/pulls", r.base, r.repo),
+		map[string]string{"Authorization": "token " + r.token},
+		map[string]string{"head": head, "base": base, "title": title, "body": body},
+		&pr)
+	return pr.HTMLURL, err
+}
+
+// postJSON posts in as JSON to u with the headers using client, decoding the
+// response into out.
+func postJSON(ctx context.Context, client *http.Client, u string, headers map[string]string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("POST %s: %s", u, resp.Status)
+		if transient.Status(resp.StatusCode) {
+			return transient.Error{Err: err}
+		}
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,98 @@
+package rig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// syncWorkdir brings the rig clone kept in the workdir up to date with the
+// rig, cloning it if the workdir is empty. Changes and branches left by an
+// earlier run are discarded: the rig branch, or the branch the rig was cloned
+// from, is fetched, checked out and reset to the rig.
+func syncWorkdir(ctx context.Context, opts Options) error {
+	repo, err := git.PlainOpen(opts.Workdir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		slog.Info("cloning rig into workdir", "dir", opts.Workdir)
+		return cloneRig(ctx, opts.Workdir, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("opening workdir: %w", err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("opening workdir: %w", err)
+	}
+	name := opts.Branch
+	if len(name) == 0 {
+		for _, b := range cfg.Branches {
+			if b.Remote == "origin" {
+				name = b.Name
+				break
+			}
+		}
+	}
+	if len(name) == 0 {
+		return fmt.Errorf("opening workdir: no branch tracks the rig")
+	}
+	branch := plumbing.NewBranchReferenceName(name)
+	remote := plumbing.NewRemoteReferenceName("origin", name)
+
+	// Fetch and reset rather than pull: pulling a sparse checkout fails, as
+	// the files outside it are seen as unstaged deletions.
+	auth, err := rigAuth(opts.URL, opts)
+	if err != nil {
+		return err
+	}
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       auth,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", branch, remote))},
+		Depth:      1,
+		Force:      true,
+	})
+	// Fetching into a shallow clone that is already up to date fails with
+	// an empty upload-pack request rather than NoErrAlreadyUpToDate.
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) && !errors.Is(err, transport.ErrEmptyUploadPackRequest) {
+		return fmt.Errorf("%w: fetching: %w", ErrClone, err)
+	}
+	ref, err := repo.Reference(remote, true)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrClone, err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	dirs, err := sparseDirs(repo, ref.Hash(), opts)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrClone, err)
+	}
+	checkout := &git.CheckoutOptions{Branch: branch, Force: true, SparseCheckoutDirectories: dirs}
+	if _, err := repo.Reference(branch, false); err != nil {
+		// The rig branch changed since the workdir was cloned.
+		checkout.Create = true
+		checkout.Hash = ref.Hash()
+	}
+	err = wt.Checkout(checkout)
+	if err != nil {
+		return fmt.Errorf("checking out %s: %w", branch.Short(), err)
+	}
+	err = wt.ResetSparsely(&git.ResetOptions{Commit: ref.Hash(), Mode: git.HardReset}, dirs)
+	if err != nil {
+		return fmt.Errorf("resetting %s: %w", branch.Short(), err)
+	}
+	err = wt.Clean(&git.CleanOptions{Dir: true})
+	if err != nil {
+		return fmt.Errorf("cleaning workdir: %w", err)
+	}
+	return nil
+}
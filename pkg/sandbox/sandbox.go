@@ -0,0 +1,201 @@
+// Package sandbox installs foods into temporary prefixes, as gofish installs
+// them into its home, to check that their packages install cleanly.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/arbourd/gfb/pkg/checksum"
+	"github.com/fishworks/gofish"
+	"github.com/mholt/archiver/v3"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Sandbox is a package of a food installed into a temporary directory.
+type Sandbox struct {
+	// Dir is the root of the sandbox, holding Barrel and Prefix.
+	Dir string
+	// Barrel is the directory the package is unpacked into.
+	Barrel string
+	// Prefix is the prefix the resources of the package are linked into by
+	// their install paths, in place of the gofish home prefix.
+	Prefix string
+	// Bytes is the number of bytes downloaded to install the package.
+	Bytes int64
+}
+
+// Install downloads pkg of f with client, verifies its checksum, unpacks it
+// and links its resources into a new sandbox. The install scripts of f are
+// not run. The sandbox is removed if the install fails.
+func Install(ctx context.Context, client *http.Client, f gofish.Food, pkg *gofish.Package) (*Sandbox, error) {
+	u, err := url.Parse(pkg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing package URL: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "gfb-sandbox-")
+	if err != nil {
+		return nil, err
+	}
+	s := &Sandbox{
+		Dir:    dir,
+		Barrel: filepath.Join(dir, "barrel", f.Name, f.Version),
+		Prefix: filepath.Join(dir, "prefix"),
+	}
+	if err := s.install(ctx, client, pkg, path.Base(u.Path)); err != nil {
+		s.Remove()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sandbox) install(ctx context.Context, client *http.Client, pkg *gofish.Package, name string) error {
+	archive := filepath.Join(s.Dir, name)
+	out, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	sha, n, err := checksum.DownloadTo(ctx, client, pkg.URL, out)
+	s.Bytes = n
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sha, pkg.SHA256) {
+		return fmt.Errorf("checksum of %s is %s, not %s", pkg.URL, sha, pkg.SHA256)
+	}
+
+	if err := os.MkdirAll(s.Barrel, 0755); err != nil {
+		return err
+	}
+	if _, err := archiver.ByExtension(name); err == nil {
+		if err := archiver.Unarchive(archive, s.Barrel); err != nil {
+			return fmt.Errorf("unpacking %s: %w", name, err)
+		}
+	} else if err := copyFile(archive, filepath.Join(s.Barrel, name)); err != nil {
+		return err
+	}
+
+	for _, r := range pkg.Resources {
+		if err := s.link(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// link links resource r of the package into the prefix of the sandbox.
+func (s *Sandbox) link(r *gofish.Resource) error {
+	src := filepath.Join(s.Barrel, r.Path)
+	if _, err := os.Lstat(src); err != nil {
+		return fmt.Errorf("resource %s is not in the package", r.Path)
+	}
+	if r.Executable {
+		if err := os.Chmod(src, 0755); err != nil {
+			return err
+		}
+	}
+	dest := filepath.Join(s.Prefix, r.InstallPath)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Symlink(src, dest); err != nil {
+		return fmt.Errorf("linking %s: %w", r.InstallPath, err)
+	}
+	return nil
+}
+
+// Run runs the resource of the package installed at installPath with args,
+// from the root of the sandbox, and returns its combined output. The
+// resource is not trusted, so it is run with a minimal environment rather
+// than that of gfb, which holds its tokens and passwords: only PATH, and HOME
+// and TMPDIR in the sandbox.
+func (s *Sandbox) Run(ctx context.Context, installPath string, args ...string) ([]byte, error) {
+	tmp := filepath.Join(s.Dir, "tmp")
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, filepath.Join(s.Prefix, installPath), args...)
+	cmd.Dir = s.Dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + s.Dir,
+		"TMPDIR=" + tmp,
+	}
+	return cmd.CombinedOutput()
+}
+
+// Test runs the test function src, the lua source of the food installed in
+// the sandbox, defines, if any. test is called with the prefix of the
+// sandbox, and may call run(installpath, args...) to run a resource of the
+// package, which returns its output and whether it exited successfully. The
+// test fails if it raises an error or returns false. Test returns false if
+// src defines no test.
+func (s *Sandbox) Test(ctx context.Context, src string) (bool, error) {
+	L := lua.NewState()
+	defer L.Close()
+	L.SetContext(ctx)
+	if err := L.DoString(src); err != nil {
+		return false, err
+	}
+	fn, ok := L.GetGlobal("test").(*lua.LFunction)
+	if !ok {
+		return false, nil
+	}
+
+	L.SetGlobal("run", L.NewFunction(func(L *lua.LState) int {
+		installPath := L.CheckString(1)
+		var args []string
+		for i := 2; i <= L.GetTop(); i++ {
+			args = append(args, L.CheckString(i))
+		}
+		out, err := s.Run(ctx, installPath, args...)
+		L.Push(lua.LString(out))
+		L.Push(lua.LBool(err == nil))
+		return 2
+	}))
+	if err := L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, lua.LString(s.Prefix)); err != nil {
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			return true, fmt.Errorf("test: %s", apiErr.Object)
+		}
+		return true, fmt.Errorf("test: %w", err)
+	}
+	if L.Get(-1) == lua.LFalse {
+		return true, errors.New("test returned false")
+	}
+	return true, nil
+}
+
+// Remove removes the sandbox.
+func (s *Sandbox) Remove() error {
+	return os.RemoveAll(s.Dir)
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
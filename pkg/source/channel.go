@@ -0,0 +1,66 @@
+package source
+
+import (
+	"regexp"
+
+	"github.com/Masterminds/semver"
+	"github.com/fishworks/gofish"
+)
+
+// Release channels foods track, from the most to the least stable. Foods
+// track the stable channel unless configured otherwise.
+const (
+	ChannelStable  = "stable"
+	ChannelRC      = "rc"
+	ChannelNightly = "nightly"
+)
+
+// channelRank orders the channels, so that each channel includes the
+// releases of the channels more stable than it.
+var channelRank = map[string]int{
+	ChannelStable:  0,
+	ChannelRC:      1,
+	ChannelNightly: 2,
+}
+
+// nightlyRegex matches the tags of nightly builds, such as v1.2.0-nightly.20240101,
+// 1.3.0-dev.5 or snapshot-2024-01-01.
+var nightlyRegex = regexp.MustCompile(`(?i)(^|[^a-z])(nightly|dev|snapshot|edge|canary)([^a-z]|$)`)
+
+// ValidChannel reports whether channel is a release channel.
+func ValidChannel(channel string) bool {
+	_, ok := channelRank[channel]
+	return ok
+}
+
+// FoodChannel returns the release channel f tracks: its channel in channels,
+// or else stable.
+func FoodChannel(f gofish.Food, channels map[string]string) string {
+	if c, ok := channels[f.Name]; ok {
+		return c
+	}
+	return ChannelStable
+}
+
+// ReleaseChannel returns the channel of the release tagged tag: nightly if the
+// tag names a nightly build, rc if the release is marked a prerelease or its
+// tag is a prerelease version, such as v1.2.0-rc.1, and stable otherwise.
+func ReleaseChannel(tag string, prerelease bool) string {
+	if nightlyRegex.MatchString(tag) {
+		return ChannelNightly
+	}
+	if prerelease {
+		return ChannelRC
+	}
+	if v, err := semver.NewVersion(tag); err == nil && len(v.Prerelease()) > 0 {
+		return ChannelRC
+	}
+	return ChannelStable
+}
+
+// onChannel reports whether a release of channel release is bumped to by
+// foods tracking channel: stable releases are on every channel, and release
+// candidates on the rc and nightly channels.
+func onChannel(channel, release string) bool {
+	return channelRank[release] <= channelRank[channel]
+}
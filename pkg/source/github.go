@@ -0,0 +1,130 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/fishworks/gofish"
+	"github.com/google/go-github/v39/github"
+)
+
+// Github resolves releases of foods released on GitHub.
+type Github struct {
+	Client *github.Client
+	// Release overrides the repository foods are released from.
+	Release map[string]GithubRelease
+	// Constraints maps foods to a semver constraint that releases must
+	// satisfy.
+	Constraints map[string]string
+	// Channels maps foods to the release channel they track, if not stable.
+	Channels map[string]string
+	// HTTPClient follows the redirects of homepages off GitHub, to find
+	// foods released on GitHub behind a custom domain. Homepages are not
+	// followed if it is nil.
+	HTTPClient *http.Client
+}
+
+// LatestVersion returns the latest release of the GitHub repository f is
+// released from, or its homepage redirects to. Repositories that were
+// deleted or archived are gone.
+func (g *Github) LatestVersion(ctx context.Context, f gofish.Food) (Version, []Asset, error) {
+	org, repo := g.repo(ctx, f)
+	if len(org) == 0 {
+		return Version{}, nil, ErrNoUpstream
+	}
+
+	repository, _, err := g.Client.Repositories.Get(ctx, org, repo)
+	if err != nil {
+		var errResp *github.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+			return Version{}, nil, &GoneError{Reason: fmt.Sprintf("upstream %s/%s no longer exists", org, repo)}
+		}
+		return Version{}, nil, fmt.Errorf("github repository: %w", err)
+	}
+	if repository.GetArchived() {
+		return Version{}, nil, &GoneError{Reason: fmt.Sprintf("upstream %s/%s is archived", org, repo)}
+	}
+
+	release, err := LatestRelease(ctx, g.Client, org, repo, Constraint(f, g.Constraints), FoodChannel(f, g.Channels))
+	if err != nil {
+		return Version{}, nil, fmt.Errorf("github release: %w", err)
+	}
+
+	var assets []Asset
+	for _, a := range release.Assets {
+		assets = append(assets, Asset{Name: a.GetName(), URL: a.GetBrowserDownloadURL(), Size: int64(a.GetSize())})
+	}
+	v := Version{
+		Tag:         release.GetTagName(),
+		URL:         release.GetHTMLURL(),
+		Description: repository.GetDescription(),
+	}
+	return v, assets, nil
+}
+
+// HasRelease reports whether the GitHub repository f is released from has a
+// release of version, tagged with or without a leading v, or with the tag of
+// version its packages are downloaded from.
+func (g *Github) HasRelease(ctx context.Context, f gofish.Food, version string) (bool, error) {
+	org, repo := g.repo(ctx, f)
+	if len(org) == 0 {
+		return false, ErrNoUpstream
+	}
+
+	tags := []string{version, "v" + version}
+	for _, pkg := range f.Packages {
+		pkgOrg, pkgRepo, tag := PackageRelease(pkg.URL)
+		if strings.EqualFold(pkgOrg, org) && strings.EqualFold(pkgRepo, repo) && strings.Contains(tag, version) && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range tags {
+		_, _, err := g.Client.Repositories.GetReleaseByTag(ctx, org, repo, tag)
+		var errResp *github.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("github release: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// repo returns the GitHub org and repo f is released from, or its homepage
+// redirects to, or empty strings if it has no GitHub upstream.
+func (g *Github) repo(ctx context.Context, f gofish.Food) (string, string) {
+	org, repo := GithubRepo(f, g.Release)
+	if len(org) == 0 && g.HTTPClient != nil {
+		org, repo = redirectRepo(ctx, g.HTTPClient, f.Homepage)
+	}
+	return org, repo
+}
+
+// redirectRepo returns the GitHub org and repo homepage redirects to, or
+// empty strings if it does not redirect to GitHub.
+func redirectRepo(ctx context.Context, client *http.Client, homepage string) (string, string) {
+	if !strings.HasPrefix(homepage, "https://") && !strings.HasPrefix(homepage, "http://") {
+		return "", ""
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, homepage, nil)
+	if err != nil {
+		return "", ""
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", ""
+	}
+	resp.Body.Close()
+
+	m := GithubRegex.FindStringSubmatch(resp.Request.URL.String())
+	if m == nil {
+		return "", ""
+	}
+	return m[1], m[2]
+}
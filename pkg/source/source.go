@@ -0,0 +1,305 @@
+// Package source resolves the upstream releases foods are bumped to.
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/fishworks/gofish"
+	"github.com/google/go-github/v39/github"
+)
+
+// ErrNoUpstream is returned by sources that do not release the food.
+var ErrNoUpstream = errors.New("no available release")
+
+// Source resolves the latest release of the upstream of foods.
+type Source interface {
+	// LatestVersion returns the latest release of the upstream of f, and the
+	// files attached to it. It returns ErrNoUpstream if f is not released
+	// from the source, and a *GoneError if its upstream is gone.
+	LatestVersion(ctx context.Context, f gofish.Food) (Version, []Asset, error)
+}
+
+// Releaser is implemented by sources that can tell whether a release of the
+// upstream of a food still exists.
+type Releaser interface {
+	// HasRelease reports whether the upstream of f has a release of version.
+	// It returns ErrNoUpstream if f is not released from the source.
+	HasRelease(ctx context.Context, f gofish.Food, version string) (bool, error)
+}
+
+// Version is a release of an upstream.
+type Version struct {
+	// Tag is the name of the release, parsed as a semantic version.
+	Tag string
+	// URL is the page of the release, if any.
+	URL string
+	// Description is the description of the upstream project, if known.
+	Description string
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string
+	URL  string
+	Size int64
+}
+
+// GoneError is returned by sources when the upstream of a food no longer
+// exists or is no longer maintained.
+type GoneError struct {
+	Reason string
+}
+
+func (e *GoneError) Error() string {
+	return e.Reason
+}
+
+// Latest returns the latest release of f from the first of sources that
+// releases it.
+func Latest(ctx context.Context, sources []Source, f gofish.Food) (Version, []Asset, error) {
+	for _, s := range sources {
+		v, assets, err := s.LatestVersion(ctx, f)
+		if errors.Is(err, ErrNoUpstream) {
+			continue
+		}
+		return v, assets, err
+	}
+	return Version{}, nil, ErrNoUpstream
+}
+
+// Released reports whether the upstream of f still has a release of version,
+// asking the first of sources that releases f. It reports true if that source
+// cannot tell.
+func Released(ctx context.Context, sources []Source, f gofish.Food, version string) (bool, error) {
+	for _, s := range sources {
+		r, ok := s.(Releaser)
+		if !ok {
+			_, _, err := s.LatestVersion(ctx, f)
+			if errors.Is(err, ErrNoUpstream) {
+				continue
+			}
+			return true, nil
+		}
+		released, err := r.HasRelease(ctx, f, version)
+		if errors.Is(err, ErrNoUpstream) {
+			continue
+		}
+		return released, err
+	}
+	return true, nil
+}
+
+// GithubRelease is the GitHub repository a food is released from.
+type GithubRelease struct {
+	Org  string
+	Repo string
+}
+
+// GithubRegex matches the org and repo of GitHub URLs, such as repository
+// pages, clone URLs ending in .git, and release download URLs.
+var GithubRegex = regexp.MustCompile(`^https://github\.com/(?P<org>[\w-]+)/(?P<repo>[\w.-]+?)(?:\.git)?(?:[/?#]|$)`)
+
+// PackageRelease returns the GitHub org, repo and release tag url downloads
+// an asset of, or empty strings if url is not a GitHub release download.
+func PackageRelease(url string) (string, string, string) {
+	m := GithubRegex.FindStringSubmatch(url)
+	if m == nil || !strings.HasSuffix(m[0], "/") {
+		return "", "", ""
+	}
+	rest, ok := strings.CutPrefix(url[len(m[0]):], "releases/download/")
+	if !ok {
+		return "", "", ""
+	}
+	tag, _, ok := strings.Cut(rest, "/")
+	if !ok || len(tag) == 0 {
+		return "", "", ""
+	}
+	return m[1], m[2], tag
+}
+
+// ReleaseURL returns the URL of the GitHub repository f is released from: its
+// release override in rmap, or else its first package URL or homepage if on
+// GitHub. It is empty if f has no GitHub upstream.
+func ReleaseURL(f gofish.Food, rmap map[string]GithubRelease) string {
+	name, _ := SplitPin(f.Name)
+	if release, ok := rmap[name]; ok {
+		return fmt.Sprintf("https://github.com/%s/%s", release.Org, release.Repo)
+	}
+	if strings.HasPrefix(f.Packages[0].URL, "https://github.com/") {
+		return f.Packages[0].URL
+	}
+	if strings.HasPrefix(f.Homepage, "https://github.com/") {
+		return f.Homepage
+	}
+
+	return ""
+}
+
+// GithubRepo returns the GitHub org and repo that f is released from, or empty
+// strings if f has no GitHub upstream.
+func GithubRepo(f gofish.Food, rmap map[string]GithubRelease) (string, string) {
+	url := ReleaseURL(f, rmap)
+	if len(url) == 0 {
+		return "", ""
+	}
+
+	results := GithubRegex.FindAllStringSubmatch(url, -1)
+	if len(results) == 0 {
+		return "", ""
+	}
+	return results[0][1], results[0][2]
+}
+
+// SplitPin splits a pinned food name like terraform@0.13 into its name and
+// pinned release line. pin is empty for foods that are not pinned.
+func SplitPin(name string) (string, string) {
+	parts := strings.SplitN(name, "@", 2)
+	if len(parts) == 1 {
+		return name, ""
+	}
+	return parts[0], parts[1]
+}
+
+// Constraint returns the semver constraint that releases must satisfy to be
+// bumped to: the pinned release line of the food, and any constraint set for
+// it in constraints. It is empty if the food is unconstrained.
+func Constraint(f gofish.Food, constraints map[string]string) string {
+	var cs []string
+	if _, pin := SplitPin(f.Name); len(pin) > 0 {
+		cs = append(cs, "~"+pin)
+	}
+	if c, ok := constraints[f.Name]; ok {
+		cs = append(cs, c)
+	}
+	return strings.Join(cs, ", ")
+}
+
+// LatestRelease returns the newest release of org/repo on channel satisfying
+// constraint, if it is set. Upstreams maintaining several release lines at
+// once publish backports after their newer releases, so rather than trusting
+// the release GitHub marks latest, the newest release of each line is found
+// and the greatest of them returned. Repositories whose releases are not
+// tagged with semantic versions fall back to the release GitHub marks latest,
+// or the newest release on channel if it is not stable.
+//
+// Releases are listed newest first, so listing stops at the first page with
+// no release greater than those before it, or after maxReleasePages pages.
+func LatestRelease(ctx context.Context, client *github.Client, org, repo, constraint, channel string) (*github.RepositoryRelease, error) {
+	var c *semver.Constraints
+	if len(constraint) > 0 {
+		var err error
+		c, err = semver.NewConstraint(constraint)
+		if err != nil {
+			return nil, fmt.Errorf("parsing constraint %s: %w", constraint, err)
+		}
+	}
+
+	var best *semver.Version
+	pages := 0
+	releases, err := listReleases(ctx, client, org, repo, channel, func(page []*github.RepositoryRelease) bool {
+		pages++
+		lines, _ := releaseLines(page, c)
+		greater := false
+		for _, l := range lines {
+			if best == nil || l.version.GreaterThan(best) {
+				best, greater = l.version, true
+			}
+		}
+		return (greater || best == nil) && pages < maxReleasePages
+	})
+	if err != nil {
+		return nil, err
+	}
+	lines, versioned := releaseLines(releases, c)
+	if !versioned && c == nil {
+		if channel != ChannelStable && len(releases) > 0 {
+			return releases[0], nil
+		}
+		release, _, err := client.Repositories.GetLatestRelease(ctx, org, repo)
+		return release, err
+	}
+
+	var latest *lineRelease
+	for _, l := range lines {
+		if latest == nil || l.version.GreaterThan(latest.version) {
+			latest = l
+		}
+	}
+	if latest == nil {
+		if c == nil {
+			return nil, fmt.Errorf("no %s release of %s/%s", channel, org, repo)
+		}
+		return nil, fmt.Errorf("no release of %s/%s matches %s", org, repo, constraint)
+	}
+	return latest.release, nil
+}
+
+// lineRelease is the newest release of a release line.
+type lineRelease struct {
+	release *github.RepositoryRelease
+	version *semver.Version
+}
+
+// releaseLines returns the newest of releases in each major.minor release
+// line, skipping releases not satisfying c if it is not nil. It reports
+// whether any release is tagged with a semantic version.
+func releaseLines(releases []*github.RepositoryRelease, c *semver.Constraints) (map[string]*lineRelease, bool) {
+	lines := map[string]*lineRelease{}
+	versioned := false
+	for _, release := range releases {
+		v, err := semver.NewVersion(release.GetTagName())
+		if err != nil {
+			continue
+		}
+		versioned = true
+		if c != nil && !c.Check(v) {
+			continue
+		}
+		line := fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+		if l, ok := lines[line]; !ok || v.GreaterThan(l.version) {
+			lines[line] = &lineRelease{release: release, version: v}
+		}
+	}
+	return lines, versioned
+}
+
+// maxReleasePages is the most pages of releases LatestRelease lists.
+const maxReleasePages = 10
+
+// ListReleases returns every published release of org/repo on channel,
+// newest first.
+func ListReleases(ctx context.Context, client *github.Client, org, repo, channel string) ([]*github.RepositoryRelease, error) {
+	return listReleases(ctx, client, org, repo, channel, nil)
+}
+
+// listReleases lists the published releases of org/repo on channel, newest
+// first. If more is set, it is called with the releases of each page, and
+// the next page is only listed if it returns true.
+func listReleases(ctx context.Context, client *github.Client, org, repo, channel string, more func([]*github.RepositoryRelease) bool) ([]*github.RepositoryRelease, error) {
+	var listed []*github.RepositoryRelease
+	listOpts := &github.ListOptions{PerPage: 100}
+	for {
+		releases, resp, err := client.Repositories.ListReleases(ctx, org, repo, listOpts)
+		if err != nil {
+			return nil, err
+		}
+		var page []*github.RepositoryRelease
+		for _, release := range releases {
+			if release.GetDraft() || !onChannel(channel, ReleaseChannel(release.GetTagName(), release.GetPrerelease())) {
+				continue
+			}
+			page = append(page, release)
+		}
+		listed = append(listed, page...)
+		if resp.NextPage == 0 || (more != nil && !more(page)) {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+	return listed, nil
+}
@@ -0,0 +1,274 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/fishworks/gofish"
+	"github.com/google/go-github/v39/github"
+)
+
+func TestGithubRegex(t *testing.T) {
+	tests := []struct {
+		url       string
+		org, repo string
+	}{
+		{"https://github.com/fishworks/fish-food", "fishworks", "fish-food"},
+		{"https://github.com/fishworks/fish-food/", "fishworks", "fish-food"},
+		{"https://github.com/fishworks/fish-food.git", "fishworks", "fish-food"},
+		{"https://github.com/vuejs/vue.js", "vuejs", "vue.js"},
+		{"https://github.com/vuejs/vue.js.git", "vuejs", "vue.js"},
+		{"https://github.com/helm/helm/tree/main/cmd", "helm", "helm"},
+		{"https://github.com/cli/cli?tab=readme-ov-file", "cli", "cli"},
+		{"https://github.com/cli/cli#installation", "cli", "cli"},
+		{"https://github.com/BurntSushi/ripgrep/releases/download/14.1.0/ripgrep-14.1.0-x86_64-apple-darwin.tar.gz", "BurntSushi", "ripgrep"},
+		{"https://github.com/docker/compose/releases/download/v2.24.0/docker-compose-linux-x86_64", "docker", "compose"},
+		{"https://gitlab.com/fishworks/fish-food", "", ""},
+		{"https://github.com/fishworks", "", ""},
+		{"http://github.com/fishworks/fish-food", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			var org, repo string
+			if m := GithubRegex.FindStringSubmatch(tt.url); m != nil {
+				org, repo = m[1], m[2]
+			}
+			if org != tt.org || repo != tt.repo {
+				t.Errorf("got %q/%q, want %q/%q", org, repo, tt.org, tt.repo)
+			}
+		})
+	}
+}
+
+func TestPackageRelease(t *testing.T) {
+	tests := []struct {
+		url            string
+		org, repo, tag string
+	}{
+		{"https://github.com/BurntSushi/ripgrep/releases/download/14.1.0/ripgrep-14.1.0-x86_64-apple-darwin.tar.gz", "BurntSushi", "ripgrep", "14.1.0"},
+		{"https://github.com/helm/chart-testing/releases/download/v3.10.1/chart-testing_3.10.1_linux_amd64.tar.gz", "helm", "chart-testing", "v3.10.1"},
+		{"https://github.com/vuejs/vue.js/releases/download/v2.7.16/vue.js", "vuejs", "vue.js", "v2.7.16"},
+		{"https://github.com/gohugoio/hugo/releases/download/v0.121.0/hugo_0.121.0_darwin-universal.tar.gz?raw=true", "gohugoio", "hugo", "v0.121.0"},
+		{"https://github.com/cli/cli/archive/refs/tags/v2.40.0.tar.gz", "", "", ""},
+		{"https://github.com/cli/cli/releases/download/v2.40.0", "", "", ""},
+		{"https://github.com/cli/cli/releases/download//gh.tar.gz", "", "", ""},
+		{"https://github.com/cli/cli", "", "", ""},
+		{"https://get.helm.sh/helm-v3.13.3-darwin-amd64.tar.gz", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			org, repo, tag := PackageRelease(tt.url)
+			if org != tt.org || repo != tt.repo || tag != tt.tag {
+				t.Errorf("got %q/%q@%q, want %q/%q@%q", org, repo, tag, tt.org, tt.repo, tt.tag)
+			}
+		})
+	}
+}
+
+func TestGithubRepo(t *testing.T) {
+	tests := []struct {
+		name      string
+		food      gofish.Food
+		rmap      map[string]GithubRelease
+		org, repo string
+	}{
+		{
+			name: "package url",
+			food: gofish.Food{
+				Name:     "ripgrep",
+				Homepage: "https://blog.burntsushi.net/ripgrep/",
+				Packages: []*gofish.Package{{URL: "https://github.com/BurntSushi/ripgrep/releases/download/14.1.0/ripgrep-14.1.0-x86_64-apple-darwin.tar.gz"}},
+			},
+			org: "BurntSushi", repo: "ripgrep",
+		},
+		{
+			name: "homepage",
+			food: gofish.Food{
+				Name:     "helm",
+				Homepage: "https://github.com/helm/helm",
+				Packages: []*gofish.Package{{URL: "https://get.helm.sh/helm-v3.13.3-darwin-amd64.tar.gz"}},
+			},
+			org: "helm", repo: "helm",
+		},
+		{
+			name: "homepage with dot and .git",
+			food: gofish.Food{
+				Name:     "vue",
+				Homepage: "https://github.com/vuejs/vue.js.git",
+				Packages: []*gofish.Package{{URL: "https://example.com/vue.tgz"}},
+			},
+			org: "vuejs", repo: "vue.js",
+		},
+		{
+			name: "release override",
+			food: gofish.Food{
+				Name:     "kubectl",
+				Homepage: "https://kubernetes.io",
+				Packages: []*gofish.Package{{URL: "https://dl.k8s.io/release/v1.29.0/bin/darwin/amd64/kubectl"}},
+			},
+			rmap: map[string]GithubRelease{"kubectl": {Org: "kubernetes", Repo: "kubernetes"}},
+			org:  "kubernetes", repo: "kubernetes",
+		},
+		{
+			name: "pinned release override",
+			food: gofish.Food{
+				Name:     "kubectl@1.28",
+				Homepage: "https://kubernetes.io",
+				Packages: []*gofish.Package{{URL: "https://dl.k8s.io/release/v1.28.0/bin/darwin/amd64/kubectl"}},
+			},
+			rmap: map[string]GithubRelease{"kubectl": {Org: "kubernetes", Repo: "kubernetes"}},
+			org:  "kubernetes", repo: "kubernetes",
+		},
+		{
+			name: "not on github",
+			food: gofish.Food{
+				Name:     "terraform",
+				Homepage: "https://www.terraform.io",
+				Packages: []*gofish.Package{{URL: "https://releases.hashicorp.com/terraform/1.6.6/terraform_1.6.6_darwin_amd64.zip"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org, repo := GithubRepo(tt.food, tt.rmap)
+			if org != tt.org || repo != tt.repo {
+				t.Errorf("got %q/%q, want %q/%q", org, repo, tt.org, tt.repo)
+			}
+		})
+	}
+}
+
+func TestReleaseLines(t *testing.T) {
+	releases := func(tags ...string) []*github.RepositoryRelease {
+		var rs []*github.RepositoryRelease
+		for _, tag := range tags {
+			rs = append(rs, &github.RepositoryRelease{TagName: github.String(tag)})
+		}
+		return rs
+	}
+	tests := []struct {
+		name       string
+		releases   []*github.RepositoryRelease
+		constraint string
+		lines      map[string]string
+		versioned  bool
+	}{
+		{
+			name:      "backport published after a newer minor",
+			releases:  releases("v1.28.5", "v1.29.1", "v1.29.0", "v1.28.4"),
+			lines:     map[string]string{"1.28": "v1.28.5", "1.29": "v1.29.1"},
+			versioned: true,
+		},
+		{
+			name:      "concurrent majors",
+			releases:  releases("16.2.0", "15.6.1", "16.1.3", "15.6.0", "16.2.1"),
+			lines:     map[string]string{"15.6": "15.6.1", "16.1": "16.1.3", "16.2": "16.2.1"},
+			versioned: true,
+		},
+		{
+			name:       "constraint",
+			releases:   releases("v1.28.5", "v1.29.1", "v1.29.0", "v1.28.4"),
+			constraint: "~1.28",
+			lines:      map[string]string{"1.28": "v1.28.5"},
+			versioned:  true,
+		},
+		{
+			name:       "no release satisfies the constraint",
+			releases:   releases("v1.28.5", "v1.29.1"),
+			constraint: ">=2",
+			lines:      map[string]string{},
+			versioned:  true,
+		},
+		{
+			name:     "no semantic versions",
+			releases: releases("nightly", "latest"),
+			lines:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c *semver.Constraints
+			if len(tt.constraint) > 0 {
+				var err error
+				c, err = semver.NewConstraint(tt.constraint)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			lines, versioned := releaseLines(tt.releases, c)
+			if versioned != tt.versioned {
+				t.Errorf("got versioned %v, want %v", versioned, tt.versioned)
+			}
+			got := map[string]string{}
+			for line, l := range lines {
+				got[line] = l.release.GetTagName()
+			}
+			if !reflect.DeepEqual(got, tt.lines) {
+				t.Errorf("got %v, want %v", got, tt.lines)
+			}
+		})
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	// Three pages of releases, newest first: the backport v1.28.5 is
+	// published after v1.29.0, and the third page has nothing newer.
+	pages := [][]string{
+		{"v1.28.5", "v1.30.0-rc.1", "v1.29.0"},
+		{"v1.28.4", "v1.29.1"},
+		{"v1.27.9", "v1.28.0"},
+	}
+	var requested []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		requested = append(requested, page)
+		if page < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d>; rel="next"`, "http://"+r.Host, r.URL.Path, page+1))
+		}
+		var releases []map[string]string
+		for _, tag := range pages[page-1] {
+			releases = append(releases, map[string]string{"tag_name": tag})
+		}
+		json.NewEncoder(w).Encode(releases)
+	}))
+	defer srv.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	tests := []struct {
+		constraint, channel string
+		tag                 string
+		pages               []int
+	}{
+		{"", ChannelStable, "v1.29.1", []int{1, 2, 3}},
+		{"", ChannelRC, "v1.30.0-rc.1", []int{1, 2}},
+		{"~1.28", ChannelStable, "v1.28.5", []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+"/"+tt.channel, func(t *testing.T) {
+			requested = nil
+			release, err := LatestRelease(context.Background(), client, "o", "r", tt.constraint, tt.channel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if release.GetTagName() != tt.tag {
+				t.Errorf("got %s, want %s", release.GetTagName(), tt.tag)
+			}
+			if !reflect.DeepEqual(requested, tt.pages) {
+				t.Errorf("listed pages %v, want %v", requested, tt.pages)
+			}
+		})
+	}
+}